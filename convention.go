@@ -0,0 +1,427 @@
+package xml2json
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// A Convention is a set of rules mapping XML documents to JSON: the keys of
+// attributes and text, how namespaces are written, which parts of the
+// document are kept... Conventions are plugins, selected with WithConvention,
+// that configure both the decoder and the encoder.
+//
+// ConvertJSON reads documents written with BadgerFish, GData and JsonML back.
+// GData tells attributes from elements as elements are written as objects,
+// so it does not apply to documents written with WithBareText or
+// WithEmptyElements. Parker drops the root element and the attributes, which
+// cannot be restored: its documents cannot be read back and are reported as
+// an *UnsupportedError.
+//
+// Custom conventions are plugins configuring the encoder and decoder with
+// their Set* methods.
+type Convention interface {
+	Plugin
+
+	// Name returns the name of the convention, e.g. "badgerfish"
+	Name() string
+}
+
+// Built-in conventions
+var (
+	// DefaultConvention prefixes attributes with "-", writes the text of
+	// elements with attributes or children under "#content", and uses
+	// arrays for repeated elements only:
+	// {"a": {"-id": "1", "#content": "x", "b": ["1", "2"]}}
+	DefaultConvention Convention = defaultConvention
+
+	// BadgerFish prefixes attributes with "@", always writes text under
+	// "$", keeps namespace prefixes in names and groups namespace
+	// declarations under "@xmlns":
+	// {"a": {"@xmlns": {"$": "urn:a", "p": "urn:p"}, "@id": "1", "b": {"$": "x"}}}
+	BadgerFish Convention = &convention{
+		name:            "badgerfish",
+		attributePrefix: "@",
+		textKey:         "$",
+		namespaceMode:   NamespacePrefix,
+		textObjects:     true,
+		namespaceObject: true,
+	}
+
+	// Parker drops attributes, the text of elements with children and the
+	// root element, keeping text-only leaves as values:
+	// {"b": ["1", "2"], "c": "x"}
+	Parker Convention = &convention{
+		name:            "parker",
+		attributePrefix: attrPrefix,
+		dropAttributes:  true,
+		dropRoot:        true,
+		dropMixedText:   true,
+	}
+
+	// GData writes attributes without prefix, text under "$t" and replaces
+	// the colon of namespace prefixes with "$", as the Google Data APIs:
+	// {"feed": {"xmlns$openSearch": "...", "openSearch$total": {"$t": "1"}}}
+	// Attributes colliding with an element keep the "-" prefix.
+	GData Convention = &convention{
+		name:            "gdata",
+		attributePrefix: attrPrefix,
+		textKey:         "$t",
+		namespaceMode:   NamespacePrefix,
+		textObjects:     true,
+		bareAttributes:  true,
+		keySeparator:    "$",
+	}
+
+	// JsonML writes elements as arrays of their name, their attributes (if
+	// any) and their children in document order:
+	// ["a", {"id": "1"}, "text", ["b", "x"]]
+	// As elements are identified by their name, JsonML only applies to
+	// whole documents.
+	JsonML Convention = &convention{
+		name:            "jsonml",
+		attributePrefix: attrPrefix,
+		namespaceMode:   NamespacePrefix,
+		jsonML:          true,
+	}
+
+	defaultConvention = &convention{name: "default", attributePrefix: attrPrefix}
+)
+
+// convention holds the rules of a built-in convention that cannot be set
+// with the Set* methods of the encoder and the decoder
+type convention struct {
+	name            string
+	attributePrefix string
+	textKey         string // key of the text of elements, the content prefix followed by "content" if empty
+	namespaceMode   NamespaceMode
+
+	textObjects     bool   // text-only elements are written as objects, e.g. {"$": "x"}
+	namespaceObject bool   // namespace declarations are grouped in an object
+	bareAttributes  bool   // attributes are written without prefix
+	keySeparator    string // replaces the colon of prefixed names in keys
+	dropAttributes  bool
+	dropRoot        bool
+	dropMixedText   bool // text of elements with children
+	jsonML          bool
+}
+
+func (c *convention) Name() string {
+	return c.name
+}
+
+func (c *convention) AddToEncoder(e *Encoder) *Encoder {
+	e.SetAttributePrefix(c.attributePrefix)
+	e.conv = c
+	return e
+}
+
+func (c *convention) AddToDecoder(d *Decoder) *Decoder {
+	d.SetAttributePrefix(c.attributePrefix)
+	d.SetNamespaceMode(c.namespaceMode)
+	if c.jsonML {
+		d.SetMixedContent(mixedDocumentOrder)
+	}
+	d.conv = c
+	return d
+}
+
+// contentKey returns the key of the text of elements
+func (dec *Decoder) contentKey() string {
+	if dec.contentKeyName != "" {
+		return dec.contentKeyName
+	}
+	if dec.conv.textKey != "" {
+		return dec.conv.textKey
+	}
+	return dec.contentPrefix + "content"
+}
+
+// contentKey returns the key of the text of elements
+func (enc *Encoder) contentKey() string {
+	if enc.contentKeyName != "" {
+		return enc.contentKeyName
+	}
+	if enc.conv.textKey != "" {
+		return enc.conv.textKey
+	}
+	return enc.contentPrefix + "content"
+}
+
+// key returns the object key of a label among the children of parent.
+// Attributes keep their prefix when written without it would collide with
+// a child element.
+func (enc *Encoder) key(parent *Node, label string) string {
+	if enc.conv.bareAttributes && enc.isAttribute(label) {
+		bare := strings.TrimPrefix(label, enc.attributePrefix)
+		if _, collides := parent.Children[bare]; !collides && bare != enc.contentKey() {
+			label = bare
+		}
+	}
+	if enc.conv.keySeparator != "" {
+		label = strings.Replace(label, ":", enc.conv.keySeparator, 1)
+	}
+	return label
+}
+
+func (enc *Encoder) isAttribute(label string) bool {
+	return enc.attributePrefix != "" && strings.HasPrefix(label, enc.attributePrefix)
+}
+
+// formatNamespaces writes the namespace declarations among the children of
+// n as a single object member, the default namespace under the text key:
+// "@xmlns": {"$": "urn:a", "p": "urn:p"}
+func (enc *Encoder) formatNamespaces(n *Node, labels []string, lvl int) {
+	enc.newline(lvl)
+	enc.writeKey(enc.key(n, enc.attributePrefix+xmlnsPrefix))
+	enc.write("{")
+	first := true
+	for _, label := range labels {
+		if !isNamespaceNode(n.Children[label]) {
+			continue
+		}
+		if !first {
+			enc.separator()
+		}
+		first = false
+
+		prefix := strings.TrimPrefix(strings.TrimPrefix(label, enc.attributePrefix), xmlnsPrefix)
+		if prefix == "" {
+			prefix = enc.contentKey()
+		}
+		enc.newline(lvl + 1)
+		enc.writeKey(strings.TrimPrefix(prefix, ":"))
+		enc.write(sanitiseString(n.Children[label][0].Data))
+	}
+	enc.newline(lvl)
+	enc.write("}")
+}
+
+// isNamespaceNode reports whether the children hold a namespace declaration
+func isNamespaceNode(children Nodes) bool {
+	return len(children) == 1 && children[0].Space == xmlnsURL
+}
+
+// formatJSONMLDocument writes the elements of a document as JsonML: a single
+// root element as an array, several ones as an array of arrays
+func (enc *Encoder) formatJSONMLDocument(ctx context.Context, root *Node, lvl int) error {
+	var labels []string
+	var nodes Nodes
+	for _, label := range root.Labels() {
+		for _, c := range root.Children[label] {
+			labels = append(labels, label)
+			nodes = append(nodes, c)
+		}
+	}
+	if len(nodes) == 1 {
+		return enc.formatJSONML(ctx, labels[0], nodes[0], lvl)
+	}
+
+	enc.write("[")
+	for i, c := range nodes {
+		if i > 0 {
+			enc.separator()
+		}
+		enc.newline(lvl + 1)
+		if err := enc.formatJSONML(ctx, labels[i], c, lvl+1); err != nil {
+			return err
+		}
+	}
+	enc.newline(lvl)
+	enc.write("]")
+	return nil
+}
+
+// formatJSONML writes an element as a JsonML array:
+// ["label", {attributes}, children...]
+func (enc *Encoder) formatJSONML(ctx context.Context, label string, n *Node, lvl int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	enc.write("[")
+	enc.newline(lvl + 1)
+	enc.write(sanitiseString(label))
+
+	labels := n.Labels()
+	var attrs, elems []string
+	for _, l := range labels {
+		if enc.isAttribute(l) {
+			attrs = append(attrs, l)
+		} else {
+			elems = append(elems, l)
+		}
+	}
+
+	if len(attrs) > 0 {
+		enc.separator()
+		enc.newline(lvl + 1)
+		enc.write("{")
+		for i, l := range attrs {
+			if i > 0 {
+				enc.separator()
+			}
+			enc.newline(lvl + 2)
+			enc.writeKey(strings.TrimPrefix(l, enc.attributePrefix))
+			enc.formatScalar(n.Children[l][0])
+		}
+		enc.newline(lvl + 1)
+		enc.write("}")
+	}
+
+	child := func(write func() error) error {
+		enc.separator()
+		enc.newline(lvl + 1)
+		return write()
+	}
+	if len(n.Segments) > 0 {
+		for _, s := range n.Segments {
+			s := s
+			err := child(func() error {
+				if s.IsText() {
+					enc.write(sanitiseString(s.Text))
+					return nil
+				}
+				return enc.formatJSONML(ctx, s.Label, s.Node, lvl+1)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		if n.Data != "" {
+			_ = child(func() error {
+				enc.formatScalar(n)
+				return nil
+			})
+		}
+		for _, l := range elems {
+			for _, c := range n.Children[l] {
+				l, c := l, c
+				if err := child(func() error { return enc.formatJSONML(ctx, l, c, lvl+1) }); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	enc.newline(lvl)
+	enc.write("]")
+	return nil
+}
+
+// decodeJSONMLDocument reads a JsonML document whose first token t has
+// already been read: a single element, or an array of elements
+func (jd *JSONDecoder) decodeJSONMLDocument(jsonDec *json.Decoder, root *Node, t json.Token) error {
+	if t != json.Delim('[') {
+		return jd.unsupported(jsonDec, "", "top-level value must be an array")
+	}
+
+	t, err := jd.token(jsonDec, "")
+	if err != nil {
+		return err
+	}
+	if name, ok := t.(string); ok {
+		return jd.decodeJSONML(jsonDec, root, name, name)
+	}
+
+	for {
+		if t != json.Delim('[') {
+			return jd.unsupported(jsonDec, "", "element must be an array")
+		}
+		if err := jd.decodeJSONMLElement(jsonDec, root, ""); err != nil {
+			return err
+		}
+		if !jsonDec.More() {
+			break
+		}
+		if t, err = jd.token(jsonDec, ""); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing bracket
+	_, err = jd.token(jsonDec, "")
+	return err
+}
+
+// decodeJSONMLElement reads an element whose opening bracket has already
+// been consumed and adds it to parent
+func (jd *JSONDecoder) decodeJSONMLElement(jsonDec *json.Decoder, parent *Node, path string) error {
+	t, err := jd.token(jsonDec, path)
+	if err != nil {
+		return err
+	}
+	name, ok := t.(string)
+	if !ok {
+		return jd.unsupported(jsonDec, path, "element name must be a string")
+	}
+	return jd.decodeJSONML(jsonDec, parent, name, join(path, name))
+}
+
+// decodeJSONML reads the attributes and children of an element whose name
+// has already been consumed, up to its closing bracket, and adds it to parent
+func (jd *JSONDecoder) decodeJSONML(jsonDec *json.Decoder, parent *Node, name, path string) error {
+	n := &Node{}
+	var segments []Segment
+	var texts []string
+	for first := true; jsonDec.More(); first = false {
+		t, err := jd.token(jsonDec, path)
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case json.Delim('{'):
+			if !first {
+				return jd.unsupported(jsonDec, path, "attributes must follow the element name")
+			}
+			for jsonDec.More() {
+				t, err := jd.token(jsonDec, path)
+				if err != nil {
+					return err
+				}
+				key := t.(string)
+				data, err := jd.scalar(jsonDec, join(path, key))
+				if err != nil {
+					return err
+				}
+				jd.addAttribute(n, jd.dec.attributePrefix+key, data)
+			}
+			// Consume the closing brace
+			if _, err := jd.token(jsonDec, path); err != nil {
+				return err
+			}
+		case json.Delim('['):
+			child := &Node{}
+			if err := jd.decodeJSONMLElement(jsonDec, child, path); err != nil {
+				return err
+			}
+			for _, label := range child.Labels() {
+				c := child.Children[label][0]
+				n.AddChild(label, c)
+				segments = append(segments, Segment{Label: label, Node: c})
+			}
+		default:
+			text := scalarString(t)
+			texts = append(texts, text)
+			segments = append(segments, Segment{Text: text})
+		}
+	}
+
+	// Text is kept in document order among elements only
+	if len(texts) > 0 && len(segments) > len(texts) {
+		n.Segments = segments
+		n.Data = trimNonGraphic(strings.Join(texts, ""))
+	} else {
+		n.Data = strings.Join(texts, "")
+	}
+	parent.AddChild(name, n)
+
+	// Consume the closing bracket
+	_, err := jd.token(jsonDec, path)
+	return err
+}
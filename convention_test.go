@@ -0,0 +1,183 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const conventionDoc = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:os="urn:os" lang="en"><os:total>2</os:total><entry id="1">a<b>x</b>c</entry><entry id="2"/><title>t</title></feed>`
+
+func TestConventions(t *testing.T) {
+	testCases := []struct {
+		convention Convention
+		expected   string
+	}{
+		{DefaultConvention, `{"feed":{"-xmlns":"http://www.w3.org/2005/Atom","-os":"urn:os","-lang":"en","total":"2","entry":[{"#content":"c","-id":"1","b":"x"},{"-id":"2"}],"title":"t"}}`},
+		{BadgerFish, `{"feed":{"@xmlns":{"$":"http://www.w3.org/2005/Atom","os":"urn:os"},"@lang":"en","os:total":{"$":"2"},"entry":[{"$":"c","@id":"1","b":{"$":"x"}},{"@id":"2"}],"title":{"$":"t"}}}`},
+		{Parker, `{"total":"2","entry":[{"b":"x"},""],"title":"t"}`},
+		{GData, `{"feed":{"xmlns":"http://www.w3.org/2005/Atom","xmlns$os":"urn:os","lang":"en","os$total":{"$t":"2"},"entry":[{"$t":"c","id":"1","b":{"$t":"x"}},{"id":"2"}],"title":{"$t":"t"}}}`},
+		{JsonML, `["feed",{"xmlns":"http://www.w3.org/2005/Atom","xmlns:os":"urn:os","lang":"en"},["os:total","2"],["entry",{"id":"1"},"a",["b","x"],"c"],["entry",{"id":"2"}],["title","t"]]`},
+	}
+
+	for _, tc := range testCases {
+		res, err := Convert(strings.NewReader(conventionDoc), WithConvention(tc.convention), WithCompact())
+		if assert.NoError(t, err, tc.convention.Name()) {
+			assert.Equal(t, tc.expected+"\n", res.String(), tc.convention.Name())
+		}
+	}
+}
+
+func TestConventionWithPlugins(t *testing.T) {
+	assert := assert.New(t)
+
+	// Plugins given after the convention adjust it
+	res, err := Convert(strings.NewReader(`<a id="1"><b>2</b></a>`), WithConvention(BadgerFish), WithAttrPrefix("_"), WithTypeConverter(Int), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"a":{"_id":1,"b":{"$":2}}}`+"\n", res.String())
+
+	// JsonML keeps the document order of elements
+	res, err = Convert(strings.NewReader(`<a><b>1</b><c/><b>2</b></a>`), WithConvention(JsonML), WithTypeConverter(Int), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`["a",["b",1],["c"],["b",2]]`+"\n", res.String())
+
+	// Several root elements, as found with WithRoot
+	res, err = Convert(strings.NewReader(`<a><b>1</b><c/></a>`), WithConvention(JsonML), WithRoot("a"), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`[["b","1"],["c"]]`+"\n", res.String())
+
+	// Parker keeps the root element when converting a subtree
+	res, err = Convert(strings.NewReader(`<a><b><c>1</c></b></a>`), WithConvention(Parker), WithRoot("a.b"), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"c":"1"}`+"\n", res.String())
+}
+
+func TestConventionRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<a id="1"><b>x</b><b>y</b></a>`
+	json, err := Convert(strings.NewReader(xml), WithConvention(BadgerFish))
+	assert.NoError(err)
+
+	res, err := ConvertJSON(json, WithConvention(BadgerFish))
+	assert.NoError(err)
+	assert.Contains(res.String(), xml)
+}
+
+func TestContentKey(t *testing.T) {
+	testCases := []struct {
+		plugins  []Plugin
+		expected string
+	}{
+		{[]Plugin{WithContentKey("#text")}, `{"a":{"#text":"x","-id":"1","b":"y"}}`},
+		{[]Plugin{WithContentPrefix("$"), WithContentKey("value")}, `{"a":{"value":"x","-id":"1","b":"y"}}`},
+		{[]Plugin{WithConvention(BadgerFish), WithContentKey("_text")}, `{"a":{"_text":"x","@id":"1","b":{"_text":"y"}}}`},
+		{[]Plugin{WithConvention(BadgerFish), WithBareText()}, `{"a":{"$":"x","@id":"1","b":"y"}}`},
+	}
+
+	for _, tc := range testCases {
+		res, err := Convert(strings.NewReader(`<a id="1">x<b>y</b></a>`), append(tc.plugins, WithCompact())...)
+		if assert.NoError(t, err, tc.expected) {
+			assert.Equal(t, tc.expected+"\n", res.String())
+		}
+	}
+}
+
+func TestBareTextAfterExclusion(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<a><price currency="EUR">10</price></a>`
+	res, err := Convert(strings.NewReader(xml), WithConvention(GData), WithExcludeElements("-currency"), WithBareText(), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"a":{"price":"10"}}`+"\n", res.String())
+
+	res, err = Convert(strings.NewReader(xml), WithConvention(GData), WithBareText(), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"a":{"price":{"$t":"10","currency":"EUR"}}}`+"\n", res.String())
+}
+
+func TestContentKeyRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<a id="1">x<b>y</b></a>`
+	json, err := Convert(strings.NewReader(xml), WithContentKey("#text"))
+	assert.NoError(err)
+
+	res, err := ConvertJSON(json, WithContentKey("#text"))
+	assert.NoError(err)
+	assert.Contains(res.String(), `<a id="1">x<b>y</b></a>`)
+}
+
+func TestConventionGDataCollision(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(`<feed a="1" b="2"><a>3</a></feed>`), WithConvention(GData), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"feed":{"-a":"1","b":"2","a":{"$t":"3"}}}`+"\n", res.String())
+}
+
+// TestConventionsRoundTrip ensures that XML -> JSON -> XML gives back the
+// same document with the conventions that can be read back
+func TestConventionsRoundTrip(t *testing.T) {
+	docs := []string{
+		conventionDoc,
+		`<feed a="1" b="2"><a>3</a><p class="x">y</p></feed>`,
+		`<a id="1"><b>x</b><b>y</b></a>`,
+	}
+
+	// Text mixed with elements and namespace prefixes are kept
+	lossless := []Plugin{WithMixedContent(MixedOrdered), WithNamespaceMode(NamespacePrefix)}
+	for _, c := range []Convention{DefaultConvention, BadgerFish, GData, JsonML} {
+		plugins := []Plugin{WithConvention(c)}
+		if c != JsonML {
+			plugins = append(plugins, lossless...)
+		}
+
+		for _, doc := range docs {
+			json, err := Convert(strings.NewReader(doc), plugins...)
+			if !assert.NoError(t, err, c.Name()) {
+				continue
+			}
+			res, err := ConvertJSON(json, plugins...)
+			if assert.NoError(t, err, "%s: %s", c.Name(), doc) {
+				assert.Equal(t, xml.Header+doc+"\n", res.String(), c.Name())
+			}
+		}
+	}
+}
+
+func TestConventionParkerJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	json, err := Convert(strings.NewReader(`<a id="1"><b>x</b><c><d>y</d></c></a>`), WithConvention(Parker))
+	assert.NoError(err)
+
+	// Whatever the number of elements below the root
+	for _, in := range []io.Reader{json, strings.NewReader(`{"a":"x"}`)} {
+		_, err = ConvertJSON(in, WithConvention(Parker))
+		var unsupportedErr *UnsupportedError
+		if assert.True(errors.As(err, &unsupportedErr), "expected an *UnsupportedError, got %v", err) {
+			assert.Equal("parker convention", unsupportedErr.Construct)
+		}
+	}
+}
+
+func TestConventionParkerMixed(t *testing.T) {
+	assert := assert.New(t)
+
+	// Text mixed with elements is dropped whatever the mixed content mode
+	res, err := Convert(strings.NewReader(`<p>Hello <b>world</b> again</p>`), WithConvention(Parker), WithMixedContent(MixedOrdered), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"b":"world"}`+"\n", res.String())
+}
+
+func TestConventionJsonMLErrors(t *testing.T) {
+	for _, in := range []string{`{"a": "x"}`, `[1]`, `["a", "x", {"id": "1"}]`, `[["a"], "x"]`} {
+		_, err := ConvertJSON(strings.NewReader(in), WithConvention(JsonML))
+		assert.True(t, errors.Is(err, ErrUnsupported), "expected an unsupported error for %s, got %v", in, err)
+	}
+}
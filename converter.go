@@ -2,14 +2,27 @@ package xml2json
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
 	"io"
 )
 
 // Convert converts the given XML document to JSON
-func Convert(r io.Reader, ps ...plugin) (*bytes.Buffer, error) {
+func Convert(r io.Reader, ps ...Plugin) (*bytes.Buffer, error) {
+	return ConvertContext(context.Background(), r, ps...)
+}
+
+// ConvertContext converts the given XML document to JSON, giving up with
+// ctx.Err() as soon as ctx is done
+func ConvertContext(ctx context.Context, r io.Reader, ps ...Plugin) (*bytes.Buffer, error) {
+	dec := NewDecoder(r, ps...)
+	if dec.linesPath != "" {
+		return convertLines(ctx, dec, ps...)
+	}
+
 	// Decode XML document
 	root := &Node{}
-	err := NewDecoder(r, ps...).Decode(root)
+	err := dec.DecodeContext(ctx, root)
 	if err != nil {
 		return nil, err
 	}
@@ -17,6 +30,44 @@ func Convert(r io.Reader, ps ...plugin) (*bytes.Buffer, error) {
 	// Then encode it in JSON
 	buf := new(bytes.Buffer)
 	e := NewEncoder(buf, ps...)
+	err = e.EncodeContext(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// convertLines converts every element found at the JSON Lines path of the
+// decoder to a line of JSON
+func convertLines(ctx context.Context, dec *Decoder, ps ...Plugin) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	enc := NewJSONLinesEncoder(buf, ps...)
+	dec.Handle(dec.linesPath, func(n *Node) error {
+		return enc.EncodeContext(ctx, n)
+	})
+
+	err := dec.DecodeContext(ctx, &Node{})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// ConvertJSON converts the given JSON document, as produced by Convert, back
+// to XML
+func ConvertJSON(r io.Reader, ps ...Plugin) (*bytes.Buffer, error) {
+	// Decode JSON document
+	root := &Node{}
+	err := NewJSONDecoder(r, ps...).Decode(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Then encode it in XML
+	buf := bytes.NewBufferString(xml.Header)
+	e := NewXMLEncoder(buf, ps...)
 	err = e.Encode(root)
 	if err != nil {
 		return nil, err
@@ -1,8 +1,13 @@
 package xml2json
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	sj "github.com/bitly/go-simplejson"
 	"github.com/stretchr/testify/assert"
@@ -200,3 +205,99 @@ func TestConvertISO(t *testing.T) {
 	// Assertion
 	assert.JSONEq(string(expected), res.String(), "Drumroll")
 }
+
+// TestConvertMalformed ensures that malformed documents are not converted
+func TestConvertMalformed(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(`<osm><foo>bar</foo><node id="1">`))
+	assert.Error(err)
+	assert.Nil(res)
+}
+
+// TestConvertKeyOrder ensures that attributes come first, then elements in document order
+func TestConvertKeyOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	s := `<osm version="0.6" generator="CGImap 0.0.2"><node id="1"/><foo>bar</foo><node id="2"/><bounds/></osm>`
+
+	res, err := Convert(strings.NewReader(s))
+	assert.NoError(err)
+	assert.Equal(`{"osm": {"-version": "0.6", "-generator": "CGImap 0.0.2", "node": [{"-id": "1"}, {"-id": "2"}], "foo": "bar", "bounds": ""}}`+"\n", res.String())
+}
+
+// TestConvertJSONRoundTrip ensures that XML -> JSON -> XML gives back the same document
+func TestConvertJSONRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, ps := range [][]Plugin{
+		nil,
+		{WithAttrPrefix("@"), WithContentPrefix("$")},
+	} {
+		first, err := Convert(strings.NewReader(s), ps...)
+		assert.NoError(err)
+
+		xml, err := ConvertJSON(bytes.NewReader(first.Bytes()), ps...)
+		assert.NoError(err)
+		assert.True(strings.HasPrefix(xml.String(), `<?xml version="1.0" encoding="UTF-8"?>`))
+
+		second, err := Convert(xml, ps...)
+		assert.NoError(err)
+
+		assert.Equal(first.String(), second.String())
+	}
+}
+
+// TestConvertJSONLines ensures that matching elements are converted to one line each
+func TestConvertJSONLines(t *testing.T) {
+	assert := assert.New(t)
+
+	s := `<feed><title>t</title><entry id="1"><name>a</name></entry><entry id="2"><name>b</name></entry></feed>`
+
+	res, err := Convert(strings.NewReader(s), WithIndent("", "  "), WithJSONLines("feed.entry"))
+	assert.NoError(err)
+	assert.Equal(`{"-id":"1","name":"a"}`+"\n"+`{"-id":"2","name":"b"}`+"\n", res.String())
+
+	for _, line := range strings.Split(strings.TrimSpace(res.String()), "\n") {
+		assert.True(json.Valid([]byte(line)))
+	}
+}
+
+func TestConvertWithRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(soapEnvelope), WithRoot("soap:Envelope.soap:Body.b:id"), WithNamespaceMode(NamespacePrefix))
+	assert.NoError(err)
+	assert.Equal(`{"#content": "2", "-b:ref": "x"}`+"\n", res.String())
+
+	res, err = Convert(strings.NewReader(s), WithRoot("**.tag"), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"-k":"name","-v":"Neu Broderstorf"}`+"\n", res.String())
+
+	// Nothing found is an error, unlike an empty element
+	res, err = Convert(strings.NewReader(s), WithRoot("osm.way"))
+	assert.True(errors.Is(err, ErrNotFound), "expected a not found error, got %v", err)
+	assert.Nil(res)
+
+	res, err = Convert(strings.NewReader(`<a><b/></a>`), WithRoot("a.b"))
+	assert.NoError(err)
+	assert.Equal(`""`+"\n", res.String())
+}
+
+func TestConvertContext(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := ConvertContext(context.Background(), strings.NewReader(`<foo>bar</foo>`))
+	assert.NoError(err)
+	assert.Equal(`{"foo": "bar"}`+"\n", res.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	res, err = ConvertContext(ctx, strings.NewReader(`<foo>bar</foo>`))
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Nil(res)
+
+	res, err = ConvertContext(ctx, strings.NewReader(`<feed><entry/></feed>`), WithJSONLines("feed.entry"))
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Nil(res)
+}
@@ -1,8 +1,11 @@
 package xml2json
 
 import (
+	"context"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"strings"
 	"unicode"
 
 	"golang.org/x/net/html/charset"
@@ -19,14 +22,62 @@ type Decoder struct {
 	err             error
 	attributePrefix string
 	contentPrefix   string
+	contentKeyName  string
 	excludeAttrs    map[string]bool
+	renames         []rename
+	renameFuncs     []RenameFunc
+	excludes        []filter
+	includes        []filter
 	formatters      []nodeFormatter
+	typeRules       []nodeFormatter
+	emptyRules      []nodeFormatter
+	schema          *Schema
+	schemaRules     []nodeFormatter
+	lenient         bool
+	schemaInstance  bool
+
+	namespaceMode     NamespaceMode
+	namespacePrefixes map[string]string
+
+	mixedMode MixedContentMode
+	textSep   string
+
+	handlers  []handler
+	linesPath string
+	rootPath  string
+
+	limits Limits
+	conv   *convention
 }
 
 type element struct {
-	parent *element
-	n      *Node
-	label  string
+	parent   *element
+	n        *Node
+	label    string
+	bindings []binding
+	segments []Segment
+	depth    int64
+	index    int            // position among the siblings with the same label
+	offset   int64          // input offset after the start tag
+	counts   map[string]int // number of children read so far, by label
+
+	// sourcePath is the path of the element before renaming, and
+	// sourceCounts the number of children read so far by label before
+	// renaming. Both are only set when renaming.
+	sourcePath   []pathElem
+	sourceCounts map[string]int
+
+	// qualified is the prefixed name of the element, only set when
+	// filtering
+	qualified string
+
+	// included is set when the element is decoded as a whole, rather than
+	// only as an ancestor of included elements
+	included bool
+
+	// rootState is the state of the root path pattern for the elements
+	// that may lead to the root, nil otherwise
+	rootState pathState
 }
 
 func (dec *Decoder) SetAttributePrefix(prefix string) {
@@ -37,8 +88,84 @@ func (dec *Decoder) SetContentPrefix(prefix string) {
 	dec.contentPrefix = prefix
 }
 
+// SetContentKey sets the whole key holding the text of elements, as read by
+// JSONDecoder, see Encoder.SetContentKey.
+func (dec *Decoder) SetContentKey(key string) {
+	dec.contentKeyName = key
+}
+
+// SetLenient controls whether Decode stops silently on malformed input.
+// In lenient mode, the elements read before the error are kept (open
+// elements are closed implicitly) and Decode returns no error.
+func (dec *Decoder) SetLenient(lenient bool) {
+	dec.lenient = lenient
+}
+
+// SetNamespaceMode controls how namespaced names are turned into labels.
+func (dec *Decoder) SetNamespaceMode(mode NamespaceMode) {
+	dec.namespaceMode = mode
+}
+
+// SetNamespacePrefixes maps namespace URIs to the prefixes used in labels,
+// regardless of the prefixes declared in the document. It implies
+// NamespacePrefix mode.
+func (dec *Decoder) SetNamespacePrefixes(prefixes map[string]string) {
+	dec.namespaceMode = NamespacePrefix
+	dec.namespacePrefixes = prefixes
+}
+
+// SetMixedContent controls how elements mixing text and child elements are
+// decoded.
+func (dec *Decoder) SetMixedContent(mode MixedContentMode) {
+	dec.mixedMode = mode
+}
+
+// SetTextSeparator sets the separator used to join the runs of text of an
+// element in MixedConcat mode. Each run is trimmed before being joined. With
+// an empty separator (default) the runs are joined untrimmed.
+func (dec *Decoder) SetTextSeparator(sep string) {
+	dec.textSep = sep
+}
+
+// SetLimits bounds the resources used to decode a document. Exceeding a
+// limit aborts the decoding with a *LimitError.
+func (dec *Decoder) SetLimits(limits Limits) {
+	dec.limits = limits
+}
+
+// SetRoot restricts the decoding to the first element found at the given
+// path (see NodePlugin), which is decoded in place of the document root.
+// Branches that cannot lead to the path are skipped without being decoded
+// and the decoding stops once the element has been read. If no element is
+// found, Decode returns a *NotFoundError and the root is left empty.
+func (dec *Decoder) SetRoot(path string) {
+	dec.rootPath = path
+}
+
+// AddFormatters registers node formatters (see NodePlugin), applied after the
+// ones already registered.
 func (dec *Decoder) AddFormatters(formatters []nodeFormatter) {
-	dec.formatters = formatters
+	dec.formatters = append(dec.formatters, formatters...)
+}
+
+// AddNodeModifier registers a modifier applied to the node found at the given
+// dotted path once the document has been decoded.
+func (dec *Decoder) AddNodeModifier(path string, m NodeModifier) {
+	dec.formatters = append(dec.formatters, NodePlugin(path, m))
+}
+
+// AddTypeRule sets the JSON type of the nodes found at the given path (see
+// NodePlugin), e.g. "osm.node.-id" as Int or "**.zip" as String. Type rules
+// take precedence over the type converter of the encoder and are applied
+// after the node modifiers, but not over the types given by the document
+// (see SetSchemaInstance). When several rules match a node, the last one
+// added wins.
+func (dec *Decoder) AddTypeRule(path string, t JSType) {
+	dec.typeRules = append(dec.typeRules, NodePlugin(path, NodeModifierFunc(func(n *Node) {
+		if !n.instanceTyped {
+			n.SetType(t)
+		}
+	})))
 }
 
 func (dec *Decoder) ExcludeAttributes(attrs []string) {
@@ -54,31 +181,113 @@ func (dec *Decoder) DecodeWithCustomPrefixes(root *Node, contentPrefix string, a
 }
 
 // NewDecoder returns a new decoder that reads from r.
-func NewDecoder(r io.Reader, plugins ...plugin) *Decoder {
-	d := &Decoder{r: r, contentPrefix: contentPrefix, attributePrefix: attrPrefix, excludeAttrs: map[string]bool{}}
+func NewDecoder(r io.Reader, plugins ...Plugin) *Decoder {
+	d := &Decoder{r: r, contentPrefix: contentPrefix, attributePrefix: attrPrefix, excludeAttrs: map[string]bool{}, conv: defaultConvention}
 	for _, p := range plugins {
 		d = p.AddToDecoder(d)
 	}
 	return d
 }
 
-// Decode reads the next JSON-encoded value from its
-// input and stores it in the value pointed to by v.
+// Decode reads the XML document from its input and stores it in root.
+//
+// A malformed document is reported as a *SyntaxError and a failing reader
+// as a *ReadError, unless the decoder is lenient. An unknown charset is
+// reported as a *CharsetError, a failing node plugin as a *PluginError and an
+// exceeded limit as a *LimitError.
 func (dec *Decoder) Decode(root *Node) error {
-	xmlDec := xml.NewDecoder(dec.r)
+	return dec.DecodeContext(context.Background(), root)
+}
+
+// DecodeContext is like Decode, but checks ctx between tokens and before
+// each read from the input. If ctx is done before the decoding completes,
+// ctx.Err() is returned.
+func (dec *Decoder) DecodeContext(ctx context.Context, root *Node) error {
+	r := dec.r
+	if ctx.Done() != nil {
+		r = &contextReader{ctx: ctx, r: r}
+	}
+	if dec.limits.MaxBytes > 0 {
+		r = &limitReader{r: r, max: dec.limits.MaxBytes}
+	}
+	var tokens *tokenReader
+	if max := dec.limits.tokenBytes(); max > 0 {
+		tokens = newTokenReader(r, max)
+		r = tokens
+	}
+	xmlDec := xml.NewDecoder(r)
 
 	// That will convert the charset if the provided XML is non-UTF-8
-	xmlDec.CharsetReader = charset.NewReaderLabel
+	var charsetErr *CharsetError
+	xmlDec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		r, err := charset.NewReaderLabel(label, input)
+		if err != nil {
+			charsetErr = &CharsetError{Charset: label, Err: err}
+		}
+		return r, err
+	}
 
 	// Create first element from the root node
 	elem := &element{
-		parent: nil,
-		n:      root,
+		parent:   nil,
+		n:        root,
+		included: len(dec.includes) == 0,
 	}
+	var count int64
+	var entities map[string]bool // declared in the document type
 
+	if err := dec.checkFilters(); err != nil {
+		return err
+	}
+	dec.schemaRules = nil
+	if dec.schema != nil {
+		dec.schemaRules = dec.schema.formatters(dec.attributePrefix)
+	}
+
+	// Only the element found at the root path (if any) is decoded
+	var rootPattern pathPattern
+	var selected *element
+	var selectedPath []pathElem
+	if dec.rootPath != "" {
+		var err error
+		if rootPattern, err = compilePath(dec.rootPath); err != nil {
+			return &PluginError{Position: Position{Path: dec.rootPath}, Plugin: "root", Err: err}
+		}
+		elem.rootState = rootPattern.start()
+	}
+
+decoding:
 	for {
-		t, _ := xmlDec.Token()
-		if t == nil {
+		tokens.reset()
+		t, err := xmlDec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if charsetErr != nil {
+			charsetErr.Position = Position{Offset: xmlDec.InputOffset()}
+			return charsetErr
+		}
+		if _, ok := err.(*LimitError); ok {
+			return dec.limitError(xmlDec, elem, err)
+		}
+		if err != nil {
+			if !dec.lenient {
+				return newDecodeError(xmlDec, elem, err, entities)
+			}
+
+			// Keep whatever has been read so far by closing open elements
+			for ; elem.parent != nil && elem.rootState == nil && elem != selected; elem = elem.parent {
+				elem.closeText(dec.mixedMode, dec.textSep)
+				elem.parent.n.AddChild(elem.label, elem.n)
+				elem.parent.addElement(dec.mixedMode, elem.label, elem.n)
+			}
+			if selected != nil {
+				selected.closeText(dec.mixedMode, dec.textSep)
+				*root = *selected.n
+			}
 			break
 		}
 
@@ -87,24 +296,128 @@ func (dec *Decoder) Decode(root *Node) error {
 			// Build new a new current element and link it to its parent
 			elem = &element{
 				parent: elem,
-				n:      &Node{},
-				label:  se.Name.Local,
+				n:      &Node{Space: se.Name.Space},
+				depth:  elem.depth + 1,
+			}
+			elem.bindNamespaces(se.Attr)
+			elem.label = dec.qualify(elem, se.Name, false)
+			if err := dec.renameElement(elem); err != nil {
+				return err
+			}
+			elem.index = elem.parent.count(elem.label)
+			elem.offset = xmlDec.InputOffset()
+
+			// Limits apply to the elements skipped below as well
+			count++
+			if err := dec.limits.checkElement(elem.depth, se, count); err != nil {
+				return dec.limitError(xmlDec, elem, err)
+			}
+
+			// Skip the branches filtered out or that cannot lead to the root path
+			keep := dec.filterElement(elem, se.Name)
+			if st := elem.parent.rootState; keep && st != nil {
+				st = rootPattern.next(st, pathElem{label: elem.label, index: elem.index})
+				switch {
+				case len(st) == 0:
+					keep = false
+				case rootPattern.accepts(st):
+					selected, selectedPath = elem, elem.stack()
+				default:
+					elem.rootState = st
+				}
+			}
+			if !keep {
+				err := dec.skip(xmlDec, tokens, elem.depth, &count)
+				if _, ok := err.(*LimitError); ok {
+					return dec.limitError(xmlDec, elem, err)
+				}
+				elem = elem.parent
+				if err != nil && !dec.lenient {
+					return newDecodeError(xmlDec, elem, err, entities)
+				}
+				continue
 			}
 
 			// Extract attributes as children
 			for _, a := range se.Attr {
-				if _, ok := dec.excludeAttrs[a.Name.Local]; ok {
+				if elem.rootState != nil {
+					break
+				}
+				if dec.schemaInstanceAttr(elem, a) || dec.conv.dropAttributes {
 					continue
 				}
-				elem.n.AddChild(dec.attributePrefix+a.Name.Local, &Node{Data: a.Value})
+				label := dec.qualify(elem, a.Name, true)
+				if dec.excludeAttrs[a.Name.Local] || dec.excludeAttrs[label] {
+					continue
+				}
+				space := a.Name.Space
+				if isNamespaceDecl(a.Name) {
+					space = xmlnsURL
+					if prefix, ok := dec.namespacePrefixes[a.Value]; ok {
+						// Declare the namespace with the registered prefix
+						label = qualifiedName(xmlnsPrefix, prefix)
+					}
+				} else {
+					var err error
+					if label, err = dec.renameAttribute(elem, label); err != nil {
+						return err
+					}
+				}
+				if !dec.filterAttribute(elem, a.Name, dec.attributePrefix+label) {
+					continue
+				}
+				elem.n.AddChild(dec.attributePrefix+label, &Node{Data: a.Value, Space: space})
 			}
 		case xml.CharData:
 			// Extract XML data (if any)
-			elem.n.Data = trimNonGraphic(string(xml.CharData(se)))
+			if err := dec.limits.checkText(string(se)); err != nil {
+				return dec.limitError(xmlDec, elem, err)
+			}
+			if elem.rootState == nil && elem.included {
+				elem.addText(dec.mixedMode, string(xml.CharData(se)))
+			}
+		case xml.Directive:
+			// Entities declared in the internal DTD subset are not expanded,
+			// references to them are reported once found
+			for _, m := range entityDecl.FindAllSubmatch(se, -1) {
+				if entities == nil {
+					entities = map[string]bool{}
+				}
+				entities[string(m[1])] = true
+			}
 		case xml.EndElement:
-			// And add it to its parent list
-			if elem.parent != nil {
+			// Elements leading to the root path are not part of the output
+			if elem.rootState != nil {
+				elem = elem.parent
+				continue
+			}
+			elem.closeText(dec.mixedMode, dec.textSep)
+
+			// The end of self-closing elements is not read from the input
+			elem.n.SelfClosing = xmlDec.InputOffset() == elem.offset
+
+			// Hand matching elements over to their handler instead of keeping them
+			handled, err := dec.handle(elem)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				return err
+			}
+
+			// The element found at the root path replaces the document root
+			if elem == selected {
+				if !handled {
+					*root = *elem.n
+				}
+				break decoding
+			}
+
+			// And add it to its parent list, unless it only was an ancestor of
+			// included elements and none has been found
+			if elem.parent != nil && !handled && (elem.included || len(elem.n.Children) > 0) {
 				elem.parent.n.AddChild(elem.label, elem.n)
+				elem.parent.addElement(dec.mixedMode, elem.label, elem.n)
 			}
 
 			// Then change the current element to its parent
@@ -112,13 +425,125 @@ func (dec *Decoder) Decode(root *Node) error {
 		}
 	}
 
-	for _, formatter := range dec.formatters {
-		formatter.Format(root)
+	if dec.rootPath != "" && selected == nil {
+		return &NotFoundError{Position: Position{Path: dec.rootPath, Offset: xmlDec.InputOffset()}}
 	}
 
+	if err := dec.format(selectedPath, root); err != nil {
+		return err
+	}
+
+	// The root element is not part of the output of some conventions
+	if dec.conv.dropRoot && selected == nil && len(root.Children) == 1 {
+		for _, children := range root.Children {
+			if len(children) == 1 {
+				*root = *children[0]
+			}
+		}
+	}
 	return nil
 }
 
+// format applies the node formatters to n, the node found at the given path
+// of the document (empty for the root). A panicking formatter is reported as
+// a *PluginError, at the path of the node it was modifying.
+func (dec *Decoder) format(path []pathElem, n *Node) (err error) {
+	var current *nodeFormatter
+	var currentPath string
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(error)
+			if !ok {
+				perr = fmt.Errorf("%v", r)
+			}
+			err = &PluginError{
+				Position: Position{Path: currentPath},
+				Plugin:   fmt.Sprintf("%T", current.plugin),
+				Err:      perr,
+			}
+		}
+	}()
+
+	formatters := append(append([]nodeFormatter{}, dec.formatters...), dec.schemaRules...)
+	formatters = append(append(formatters, dec.typeRules...), dec.emptyRules...)
+	for i := range formatters {
+		current = &formatters[i]
+		if current.err != nil {
+			// Invalid patterns are reported at their own path
+			currentPath = current.path
+			panic(current.err)
+		}
+
+		st := current.pattern.start()
+		for _, e := range path {
+			st = current.pattern.next(st, e)
+		}
+		if len(st) > 0 {
+			current.formatFrom(st, path, n, func(p []pathElem) {
+				currentPath = dottedPath(p)
+			})
+		}
+	}
+
+	return nil
+}
+
+// contextReader fails with ctx.Err() once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// limitError sets the position of a *LimitError raised while decoding elem
+func (dec *Decoder) limitError(xmlDec *xml.Decoder, elem *element, err error) error {
+	if limitErr, ok := err.(*LimitError); ok {
+		limitErr.Position = Position{Path: elem.path(), Offset: xmlDec.InputOffset()}
+	}
+	return err
+}
+
+// count returns the number of children with the given label read so far,
+// and counts one more
+func (e *element) count(label string) int {
+	if e.counts == nil {
+		e.counts = map[string]int{}
+	}
+	i := e.counts[label]
+	e.counts[label] = i + 1
+	return i
+}
+
+// stack returns the labels leading to the element from the document root
+func (e *element) stack() []pathElem {
+	var elems []pathElem
+	for ; e != nil && e.parent != nil; e = e.parent {
+		elems = append(elems, pathElem{label: e.label, index: e.index})
+	}
+	for i, j := 0, len(elems)-1; i < j; i, j = i+1, j-1 {
+		elems[i], elems[j] = elems[j], elems[i]
+	}
+	return elems
+}
+
+// path returns the dotted path of the element from the document root
+func (e *element) path() string {
+	var labels []string
+	for ; e != nil && e.parent != nil; e = e.parent {
+		labels = append(labels, e.label)
+	}
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
 // trimNonGraphic returns a slice of the string s, with all leading and trailing
 // non graphic characters and spaces removed.
 //
@@ -1,8 +1,12 @@
 package xml2json
 
 import (
+	"context"
+	"errors"
+	"io"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -83,3 +87,130 @@ func TestTrim(t *testing.T) {
 		assert.Equal(t, scenario.expected, got)
 	}
 }
+
+func TestDecodeSyntaxError(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader("<osm>\n<node id=\"1\">\n</osm>")).Decode(root)
+
+	var syntaxErr *SyntaxError
+	if assert.True(errors.As(err, &syntaxErr), "expected a *SyntaxError, got %v", err) {
+		assert.Equal(3, syntaxErr.Line)
+		assert.NotZero(syntaxErr.Offset)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(`<osm><foo>bar</foo><node id="1">`)).Decode(root)
+
+	var syntaxErr *SyntaxError
+	assert.True(errors.As(err, &syntaxErr), "expected a *SyntaxError, got %v", err)
+}
+
+func TestDecodeReadError(t *testing.T) {
+	assert := assert.New(t)
+
+	failure := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("<osm><foo>bar</foo>"), iotest.ErrReader(failure))
+
+	root := &Node{}
+	err := NewDecoder(r).Decode(root)
+
+	var readErr *ReadError
+	assert.True(errors.As(err, &readErr), "expected a *ReadError, got %v", err)
+	assert.True(errors.Is(err, failure))
+}
+
+func TestDecodeLenient(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(`<osm><foo>bar</foo><node id="1">`), WithLenientDecoding()).Decode(root)
+	assert.NoError(err)
+
+	assert.Equal("bar", root.GetChild("osm.foo").Data)
+	assert.Equal("1", root.GetChild("osm.node.-id").Data)
+}
+
+// cancellingReader cancels the context once the first chunk has been read
+type cancellingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (cr *cancellingReader) Read(p []byte) (int, error) {
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	n, err := cr.r.Read(p)
+	cr.cancel()
+	return n, err
+}
+
+func TestDecodeContext(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(s)).DecodeContext(context.Background(), root))
+	assert.NotNil(root.GetChild("osm.foo"))
+
+	// Cancelled before decoding
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewDecoder(strings.NewReader(s)).DecodeContext(ctx, &Node{})
+	assert.Equal(context.Canceled, err)
+
+	// Cancelled while decoding
+	ctx, cancel = context.WithCancel(context.Background())
+	r := &cancellingReader{r: strings.NewReader(s), cancel: cancel}
+	err = NewDecoder(r).DecodeContext(ctx, &Node{})
+	assert.Equal(context.Canceled, err)
+}
+
+func TestDecodeRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	dec := NewDecoder(strings.NewReader(s), WithRoot("osm.node[2]"))
+	dec.AddNodeModifier("osm.node.tag", ToArray())
+	assert.NoError(dec.Decode(root))
+
+	assert.Equal("1831881213", root.GetChild("-id").Data)
+	assert.Len(root.Children["tag"], 2)
+	assert.True(root.Children["tag"][0].ChildrenAlwaysAsArray, "paths of node plugins start from the document root")
+	assert.Nil(root.GetChild("osm"))
+
+	// Decoding stops once the element has been read
+	failure := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader(`<feed><title>t</title><entry>a</entry>`), iotest.ErrReader(failure))
+	root = &Node{}
+	assert.NoError(NewDecoder(r, WithRoot("feed.entry")).Decode(root))
+	assert.Equal("a", root.Data)
+
+	// Skipped branches must still be well formed
+	err := NewDecoder(strings.NewReader(`<a><b><c></b></a>`), WithRoot("a.x")).Decode(&Node{})
+	var syntaxErr *SyntaxError
+	assert.True(errors.As(err, &syntaxErr), "expected a *SyntaxError, got %v", err)
+
+	// No matching element
+	root = &Node{}
+	err = NewDecoder(strings.NewReader(s), WithRoot("osm.way")).Decode(root)
+	var notFoundErr *NotFoundError
+	if assert.True(errors.As(err, &notFoundErr), "expected a *NotFoundError, got %v", err) {
+		assert.True(errors.Is(err, ErrNotFound))
+		assert.Equal("osm.way", notFoundErr.Path)
+	}
+	assert.Empty(root.Children)
+
+	// An empty element is found
+	root = &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(`<a><b/></a>`), WithRoot("a.b")).Decode(root))
+	assert.Empty(root.Children)
+
+	err = NewDecoder(strings.NewReader(s), WithRoot("osm.node[x]")).Decode(&Node{})
+	assert.True(errors.Is(err, ErrPlugin))
+}
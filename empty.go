@@ -0,0 +1,74 @@
+package xml2json
+
+// EmptyMode controls how empty elements, i.e. elements without attributes,
+// children or text such as <bounds/> or <bounds></bounds>, are encoded.
+// Empty attributes are always encoded as "".
+type EmptyMode int
+
+const (
+	// EmptyString encodes empty elements as "" (default), or as {} with the
+	// conventions writing text as objects (e.g. BadgerFish)
+	EmptyString EmptyMode = iota
+	// EmptyNull encodes empty elements as null
+	EmptyNull
+	// EmptyObject encodes empty elements as {}
+	EmptyObject
+	// EmptyTrue encodes empty elements as true, e.g. for flags such as
+	// <enabled/>
+	EmptyTrue
+)
+
+// json returns the JSON encoding of empty elements
+func (m EmptyMode) json() string {
+	switch m {
+	case EmptyNull:
+		return "null"
+	case EmptyObject:
+		return "{}"
+	case EmptyTrue:
+		return "true"
+	default:
+		return `""`
+	}
+}
+
+// SetEmptyMode sets how the empty elements are encoded, unless set on the
+// nodes themselves (see Node.SetEmptyMode).
+func (enc *Encoder) SetEmptyMode(mode EmptyMode) {
+	enc.emptyMode = mode
+}
+
+// AddEmptyRule sets how the empty elements found at the given path (see
+// NodePlugin) are encoded, taking precedence over the mode of the encoder.
+// When several rules match a node, the last one added wins.
+func (dec *Decoder) AddEmptyRule(path string, mode EmptyMode) {
+	dec.emptyRules = append(dec.emptyRules, NodePlugin(path, NodeModifierFunc(func(n *Node) {
+		n.SetEmptyMode(mode)
+	})))
+}
+
+// SetEmptyMode sets how the node is encoded if it is empty, taking
+// precedence over the mode of the encoder
+func (n *Node) SetEmptyMode(mode EmptyMode) {
+	n.emptyMode = mode
+	n.emptySet = true
+}
+
+// IsEmpty reports whether the node has no children, text or type
+func (n *Node) IsEmpty() bool {
+	return len(n.Children) == 0 && len(n.Segments) == 0 && n.Data == "" && !n.typed
+}
+
+// formatEmpty writes an empty element, and reports whether it has been
+// written, i.e. whether a mode applies to it
+func (enc *Encoder) formatEmpty(n *Node) bool {
+	mode := enc.emptyMode
+	if n.emptySet {
+		mode = n.emptyMode
+	} else if mode == EmptyString {
+		return false
+	}
+
+	enc.write(mode.json())
+	return true
+}
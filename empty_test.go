@@ -0,0 +1,56 @@
+package xml2json
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const emptyDoc = `<osm version=""><bounds/><note></note><node id="1"><visible/></node></osm>`
+
+func TestEmptyElements(t *testing.T) {
+	testCases := []struct {
+		plugins  []Plugin
+		expected string
+	}{
+		{nil, `{"osm":{"-version":"","bounds":"","note":"","node":{"-id":"1","visible":""}}}`},
+		{[]Plugin{WithEmptyElements(EmptyNull)}, `{"osm":{"-version":"","bounds":null,"note":null,"node":{"-id":"1","visible":null}}}`},
+		{[]Plugin{WithEmptyElements(EmptyObject)}, `{"osm":{"-version":"","bounds":{},"note":{},"node":{"-id":"1","visible":{}}}}`},
+		{[]Plugin{WithEmptyElements(EmptyNull), WithEmptyElements(EmptyTrue, "**.visible")}, `{"osm":{"-version":"","bounds":null,"note":null,"node":{"-id":"1","visible":true}}}`},
+		{[]Plugin{WithEmptyElements(EmptyString, "osm.note"), WithEmptyElements(EmptyNull)}, `{"osm":{"-version":"","bounds":null,"note":"","node":{"-id":"1","visible":null}}}`},
+		{[]Plugin{WithConvention(BadgerFish), WithEmptyElements(EmptyNull, "osm.bounds")}, `{"osm":{"@version":"","bounds":null,"note":{},"node":{"@id":"1","visible":{}}}}`},
+	}
+
+	for _, tc := range testCases {
+		res, err := Convert(strings.NewReader(emptyDoc), append(tc.plugins, WithCompact())...)
+		if assert.NoError(t, err, tc.expected) {
+			assert.Equal(t, tc.expected+"\n", res.String())
+		}
+	}
+}
+
+func TestEmptyElementsTyped(t *testing.T) {
+	assert := assert.New(t)
+
+	// Types take precedence over the empty mode
+	res, err := Convert(strings.NewReader(emptyDoc), WithEmptyElements(EmptyTrue), WithTypeRules(map[string]JSType{"osm.note": Null}), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"osm":{"-version":"","bounds":true,"note":null,"node":{"-id":"1","visible":true}}}`+"\n", res.String())
+}
+
+func TestDecodeSelfClosing(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(emptyDoc)).Decode(root)
+	assert.NoError(err)
+
+	osm := root.GetChild("osm")
+	assert.False(osm.SelfClosing)
+	assert.True(osm.GetChild("bounds").SelfClosing)
+	assert.False(osm.GetChild("note").SelfClosing)
+	assert.True(osm.GetChild("node.visible").SelfClosing)
+	assert.True(osm.GetChild("bounds").IsEmpty())
+	assert.False(osm.GetChild("node").IsEmpty())
+}
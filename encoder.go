@@ -1,31 +1,117 @@
 package xml2json
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"unicode/utf8"
 )
 
 // An Encoder writes JSON objects to an output stream.
 type Encoder struct {
-	w               io.Writer
+	w               *bufio.Writer
+	written         int64 // bytes written to the buffer
 	err             error
 	contentPrefix   string
+	contentKeyName  string
 	attributePrefix string
-	tc              encoderTypeConverter
+	bareText        bool
+	emptyMode       EmptyMode
+	sortKeys        bool
+	compact         bool
+	prefix          string
+	indent          string
+	tc              TypeConverter
+	conv            *convention
 }
 
 // NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer, plugins ...plugin) *Encoder {
-	e := &Encoder{w: w, contentPrefix: contentPrefix, attributePrefix: attrPrefix}
+func NewEncoder(w io.Writer, plugins ...Plugin) *Encoder {
+	e := &Encoder{w: bufio.NewWriter(w), contentPrefix: contentPrefix, attributePrefix: attrPrefix, conv: defaultConvention}
 	for _, p := range plugins {
 		e = p.AddToEncoder(e)
 	}
 	return e
 }
 
+// NewJSONLinesEncoder returns a new encoder that writes each encoded value
+// as a compact JSON object on its own line (JSON Lines).
+func NewJSONLinesEncoder(w io.Writer, plugins ...Plugin) *Encoder {
+	e := NewEncoder(w, plugins...)
+	e.SetIndent("", "")
+	e.SetCompact(true)
+	return e
+}
+
+// SetAttributePrefix sets the prefix of the labels of attributes.
+func (enc *Encoder) SetAttributePrefix(prefix string) {
+	enc.attributePrefix = prefix
+}
+
+// SetContentPrefix sets the prefix of the key holding the text of elements
+// that also have children.
+func (enc *Encoder) SetContentPrefix(prefix string) {
+	enc.contentPrefix = prefix
+}
+
+// SetContentKey sets the whole key holding the text of elements that also
+// have children, e.g. "#text" or "value", instead of the content prefix
+// followed by "content". It takes precedence over the content prefix and
+// the convention.
+func (enc *Encoder) SetContentKey(key string) {
+	enc.contentKeyName = key
+}
+
+// SetBareText controls whether elements holding only text are always
+// written as their bare value, e.g. once their attributes have been
+// excluded, even if the convention writes text as an object.
+func (enc *Encoder) SetBareText(bare bool) {
+	enc.bareText = bare
+}
+
+// SetTypeConverter sets the converter applied to every leaf value. A nil
+// converter writes all values as JSON strings.
+func (enc *Encoder) SetTypeConverter(tc TypeConverter) {
+	enc.tc = tc
+}
+
+// SetSortKeys controls whether object keys are sorted alphabetically instead
+// of following the document order. Sorted keys give a canonical output.
+func (enc *Encoder) SetSortKeys(sortKeys bool) {
+	enc.sortKeys = sortKeys
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded value as
+// if indented by json.MarshalIndent: each element begins on a new line
+// starting with prefix followed by one or more copies of indent according to
+// the indentation nesting. Calling SetIndent("", "") disables indentation.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.prefix = prefix
+	enc.indent = indent
+}
+
+// SetCompact controls whether the spaces after ':' and ',' are dropped.
+func (enc *Encoder) SetCompact(compact bool) {
+	enc.compact = compact
+}
+
 // Encode writes the JSON encoding of v to the stream
 func (enc *Encoder) Encode(root *Node) error {
+	return enc.EncodeContext(context.Background(), root)
+}
+
+// EncodeContext writes the JSON encoding of v to the stream, checking ctx
+// between nodes. If ctx is done before the encoding completes, the output is
+// left incomplete and ctx.Err() is returned, by all the later calls as well.
+//
+// The output is buffered and flushed before returning. A failing writer
+// stops the encoding and is reported as a *WriteError, returned by all the
+// later calls as well.
+func (enc *Encoder) EncodeContext(ctx context.Context, root *Node) error {
 	if enc.err != nil {
 		return enc.err
 	}
@@ -33,7 +119,22 @@ func (enc *Encoder) Encode(root *Node) error {
 		return nil
 	}
 
-	enc.err = enc.format(root, 0)
+	var err error
+	if enc.conv.jsonML {
+		err = enc.formatJSONMLDocument(ctx, root, 0)
+	} else {
+		err = enc.format(ctx, root, 0)
+	}
+	if err != nil && err == ctx.Err() {
+		enc.flush()
+		if enc.err == nil {
+			enc.err = err
+		}
+		return err
+	}
+	if err != nil {
+		enc.err = err
+	}
 
 	// Terminate each value with a newline.
 	// This makes the output look a little nicer
@@ -42,70 +143,291 @@ func (enc *Encoder) Encode(root *Node) error {
 	// so that the reader knows there aren't more
 	// digits coming.
 	enc.write("\n")
+	enc.flush()
 
 	return enc.err
 }
 
-func (enc *Encoder) format(n *Node, lvl int) error {
-	if n.IsComplex() {
+func (enc *Encoder) format(ctx context.Context, n *Node, lvl int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+
+	if len(n.Segments) > 0 && !enc.conv.dropMixedText {
+		return enc.formatMixed(ctx, n, lvl)
+	} else if n.IsComplex() {
 		enc.write("{")
 
+		members := 0
+		member := func() {
+			if members > 0 {
+				enc.separator()
+			}
+			members++
+		}
+
 		// Add data as an additional attibute (if any)
-		if len(n.Data) > 0 {
-			enc.write("\"")
-			enc.write(enc.contentPrefix)
-			enc.write("content")
-			enc.write("\": ")
-			enc.write(sanitiseString(n.Data))
-			enc.write(", ")
-		}
-
-		i := 0
-		tot := len(n.Children)
-		for label, children := range n.Children {
-			enc.write("\"")
-			enc.write(label)
-			enc.write("\": ")
-
-			if n.ChildrenAlwaysAsArray || len(children) > 1 {
-				// Array
-				enc.write("[")
-				for j, c := range children {
-					enc.format(c, lvl+1)
-
-					if j < len(children)-1 {
-						enc.write(", ")
-					}
-				}
-				enc.write("]")
+		if len(n.Data) > 0 && !enc.conv.dropMixedText {
+			member()
+			enc.newline(lvl + 1)
+			enc.writeKey(enc.contentKey())
+			if t, ok := n.Type(); ok {
+				enc.write(convertAs(n.Data, t))
 			} else {
-				// Map
-				enc.format(children[0], lvl+1)
+				enc.write(sanitiseString(n.Data))
 			}
+		}
+
+		labels := n.Labels()
+		if enc.sortKeys {
+			sort.Strings(labels)
+		}
 
-			if i < tot-1 {
-				enc.write(", ")
+		namespaces := false
+		for _, label := range labels {
+			// Namespace declarations are written together, in place of the first one
+			if enc.conv.namespaceObject && isNamespaceNode(n.Children[label]) {
+				if !namespaces {
+					member()
+					enc.formatNamespaces(n, labels, lvl+1)
+					namespaces = true
+				}
+				continue
+			}
+
+			member()
+			if err := enc.formatMember(ctx, n, label, n.Children[label], n.ChildrenAlwaysAsArray, lvl+1); err != nil {
+				return err
 			}
-			i++
 		}
 
+		enc.newline(lvl)
 		enc.write("}")
+	} else {
+		enc.formatScalar(n)
+	}
+
+	return nil
+}
+
+// formatScalar writes the data of a node without children
+func (enc *Encoder) formatScalar(n *Node) {
+	if t, ok := n.Type(); ok && n.list {
+		values := strings.Fields(n.Data)
+		enc.write("[")
+		for i, v := range values {
+			enc.write(convertAs(v, t))
+			if i < len(values)-1 {
+				enc.separator()
+			}
+		}
+		enc.write("]")
+	} else if ok {
+		// Types set on the node take precedence over the converter
+		enc.write(convertAs(n.Data, t))
 	} else {
 		s := sanitiseString(n.Data)
 		if enc.tc == nil {
 			// do nothing
 		} else {
-			s = enc.tc.Convert(s)
+			s = enc.convert(s)
 		}
 		enc.write(s)
 
 	}
+}
 
+// convert applies the type converter to the JSON encoding of a value. A
+// panicking converter stops the encoding and is reported as a *PluginError,
+// at the offset of the value.
+func (enc *Encoder) convert(s string) (res string) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(error)
+			if !ok {
+				perr = fmt.Errorf("%v", r)
+			}
+			enc.err = &PluginError{
+				Position: Position{Offset: enc.written},
+				Plugin:   fmt.Sprintf("%T", enc.tc),
+				Err:      perr,
+			}
+			res = ""
+		}
+	}()
+	return enc.tc.Convert(s)
+}
+
+// formatMember writes an object member of parent holding the children with
+// the given label, either as an array or as a single value
+func (enc *Encoder) formatMember(ctx context.Context, parent *Node, label string, children Nodes, asArray bool, lvl int) error {
+	enc.newline(lvl)
+	enc.writeKey(enc.key(parent, label))
+
+	for _, c := range children {
+		asArray = asArray || c.AsArray
+	}
+	if asArray || len(children) > 1 {
+		// Array
+		enc.write("[")
+		for j, c := range children {
+			enc.newline(lvl + 1)
+			if err := enc.formatValue(ctx, label, c, lvl+1); err != nil {
+				return err
+			}
+
+			if j < len(children)-1 {
+				enc.separator()
+			}
+		}
+		enc.newline(lvl)
+		enc.write("]")
+		return nil
+	}
+
+	// Map
+	return enc.formatValue(ctx, label, children[0], lvl)
+}
+
+// formatValue writes the value of a child with the given label
+func (enc *Encoder) formatValue(ctx context.Context, label string, n *Node, lvl int) error {
+	if !enc.isAttribute(label) && n.IsEmpty() && enc.formatEmpty(n) {
+		return nil
+	}
+	if enc.conv.textObjects && !enc.bareText && !enc.isAttribute(label) && !n.IsComplex() && len(n.Segments) == 0 {
+		// Text-only elements are written as objects
+		enc.write("{")
+		if n.Data != "" {
+			enc.newline(lvl + 1)
+			enc.writeKey(enc.contentKey())
+			enc.formatScalar(n)
+			enc.newline(lvl)
+		}
+		enc.write("}")
+		return nil
+	}
+	return enc.format(ctx, n, lvl)
+}
+
+// formatMixed writes a node with mixed content: the children that are not
+// part of the content (i.e. attributes) come first, then the runs of text and
+// the child elements as an array under the content key.
+func (enc *Encoder) formatMixed(ctx context.Context, n *Node, lvl int) error {
+	enc.write("{")
+
+	inContent := map[string]bool{}
+	for _, s := range n.Segments {
+		if !s.IsText() {
+			inContent[s.Label] = true
+		}
+	}
+
+	labels := n.Labels()
+	if enc.sortKeys {
+		sort.Strings(labels)
+	}
+	for _, label := range labels {
+		if inContent[label] {
+			continue
+		}
+		if err := enc.formatMember(ctx, n, label, n.Children[label], false, lvl+1); err != nil {
+			return err
+		}
+		enc.separator()
+	}
+
+	enc.newline(lvl + 1)
+	enc.writeKey(enc.contentKey())
+	enc.write("[")
+	for i, s := range n.Segments {
+		enc.newline(lvl + 2)
+		if s.IsText() {
+			enc.write(sanitiseString(s.Text))
+		} else {
+			enc.write("{")
+			if err := enc.formatMember(ctx, n, s.Label, Nodes{s.Node}, false, lvl+3); err != nil {
+				return err
+			}
+			enc.newline(lvl + 2)
+			enc.write("}")
+		}
+
+		if i < len(n.Segments)-1 {
+			enc.separator()
+		}
+	}
+	enc.newline(lvl + 1)
+	enc.write("]")
+
+	enc.newline(lvl)
+	enc.write("}")
 	return nil
 }
 
+// writeKey writes an object key, escaped as strings are, followed by the key
+// separator
+func (enc *Encoder) writeKey(key string) {
+	enc.write(sanitiseString(key))
+	if enc.compact {
+		enc.write(":")
+	} else {
+		enc.write(": ")
+	}
+}
+
+// separator writes the separator between two values
+func (enc *Encoder) separator() {
+	if enc.compact || enc.indenting() {
+		enc.write(",")
+	} else {
+		enc.write(", ")
+	}
+}
+
+// newline starts a new line indented to the given level (if indenting)
+func (enc *Encoder) newline(lvl int) {
+	if !enc.indenting() {
+		return
+	}
+	enc.write("\n")
+	enc.write(enc.prefix)
+	for i := 0; i < lvl; i++ {
+		enc.write(enc.indent)
+	}
+}
+
+func (enc *Encoder) indenting() bool {
+	return enc.prefix != "" || enc.indent != ""
+}
+
 func (enc *Encoder) write(s string) {
-	enc.w.Write([]byte(s))
+	if enc.err != nil {
+		return
+	}
+	n, err := enc.w.WriteString(s)
+	enc.written += int64(n)
+	if err != nil {
+		enc.writeError(err)
+	}
+}
+
+// flush writes the buffered output to the underlying writer
+func (enc *Encoder) flush() {
+	if enc.err != nil {
+		return
+	}
+	if err := enc.w.Flush(); err != nil {
+		enc.writeError(err)
+	}
+}
+
+// writeError records a failure of the underlying writer, at the offset of
+// the bytes that made it out of the buffer
+func (enc *Encoder) writeError(err error) {
+	enc.err = &WriteError{Position: Position{Offset: enc.written - int64(enc.w.Buffered())}, Err: err}
 }
 
 // https://golang.org/src/encoding/json/encode.go?s=5584:5627#L788
@@ -2,8 +2,11 @@ package xml2json
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	sj "github.com/bitly/go-simplejson"
@@ -148,3 +151,156 @@ func TestEncodeWithChildrenAsExplicitArray(t *testing.T) {
 	json.Unmarshal(buf.Bytes(), &testBio)
 	assert.Equal(1, len(testBio.Hobbies))
 }
+
+// TestEncodeKeyOrder ensures that keys follow the document order, or the
+// alphabetical order when sorted keys are requested.
+func TestEncodeKeyOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	for _, label := range []string{"zulu", "alpha", "mike", "alpha", "bravo"} {
+		root.AddChild(label, &Node{Data: label})
+	}
+
+	for i := 0; i < 10; i++ {
+		buf := new(bytes.Buffer)
+		assert.NoError(NewEncoder(buf).Encode(root))
+		assert.Equal(`{"zulu": "zulu", "alpha": ["alpha", "alpha"], "mike": "mike", "bravo": "bravo"}`+"\n", buf.String())
+	}
+
+	buf := new(bytes.Buffer)
+	assert.NoError(NewEncoder(buf, WithSortedKeys()).Encode(root))
+	assert.Equal(`{"alpha": ["alpha", "alpha"], "bravo": "bravo", "mike": "mike", "zulu": "zulu"}`+"\n", buf.String())
+}
+
+// TestEncodeIndent ensures that indented output follows the json.MarshalIndent conventions
+func TestEncodeIndent(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	osm := &Node{Data: "text"}
+	osm.AddChild("-version", &Node{Data: "0.6"})
+	osm.AddChild("node", &Node{Data: "a"})
+	osm.AddChild("node", &Node{Data: "b"})
+	tag := &Node{}
+	tag.AddChild("-k", &Node{Data: "name"})
+	osm.AddChild("tag", tag)
+	root.AddChild("osm", osm)
+
+	compact := new(bytes.Buffer)
+	assert.NoError(NewEncoder(compact, WithCompact()).Encode(root))
+	assert.Equal(`{"osm":{"#content":"text","-version":"0.6","node":["a","b"],"tag":{"-k":"name"}}}`+"\n", compact.String())
+
+	for _, scenario := range []struct{ prefix, indent string }{
+		{prefix: "", indent: "  "},
+		{prefix: ">", indent: "\t"},
+		{prefix: "//", indent: ""},
+	} {
+		expected := new(bytes.Buffer)
+		assert.NoError(json.Indent(expected, bytes.TrimSpace(compact.Bytes()), scenario.prefix, scenario.indent))
+
+		buf := new(bytes.Buffer)
+		assert.NoError(NewEncoder(buf, WithIndent(scenario.prefix, scenario.indent)).Encode(root))
+		assert.Equal(expected.String()+"\n", buf.String())
+	}
+}
+
+func TestEncodeContext(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	root.AddChild("foo", &Node{Data: "bar"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	assert.Equal(context.Canceled, enc.EncodeContext(ctx, root))
+
+	// The encoder stays failed, as its output is incomplete
+	assert.Equal(context.Canceled, enc.EncodeContext(context.Background(), root))
+	assert.Equal(context.Canceled, enc.Encode(root))
+	assert.Empty(buf.String())
+}
+
+// TestEncodeEscapedKeys ensures that keys are escaped as strings are
+func TestEncodeEscapedKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	root.AddChild(`a"b\c`, &Node{Data: "1"})
+	root.AddChild("d\ne<f>", &Node{Data: "2"})
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf, WithCompact()).Encode(root)
+	assert.NoError(err)
+	assert.Equal(`{"a\"b\\c":"1","d\ne\u003cf\u003e":"2"}`+"\n", buf.String())
+
+	var v map[string]string
+	assert.NoError(json.Unmarshal(buf.Bytes(), &v))
+	assert.Equal(map[string]string{`a"b\c`: "1", "d\ne<f>": "2"}, v)
+}
+
+// limitedWriter accepts n bytes, then fails
+type limitedWriter struct {
+	n      int
+	writes int
+	buf    bytes.Buffer
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.n {
+		w.buf.Write(p[:w.n])
+		n := w.n
+		w.n = 0
+		return n, errDiskFull
+	}
+	w.n -= len(p)
+	return w.buf.Write(p)
+}
+
+// TestEncodeWriteError ensures that a failing writer stops the encoding
+func TestEncodeWriteError(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	for i := 0; i < 1000; i++ {
+		root.AddChild("node", &Node{Data: strings.Repeat("x", 10)})
+	}
+
+	w := &limitedWriter{n: 5000}
+	enc := NewEncoder(w)
+	err := enc.Encode(root)
+
+	var writeErr *WriteError
+	if assert.True(errors.As(err, &writeErr)) {
+		assert.True(errors.Is(err, ErrWrite))
+		assert.True(errors.Is(err, errDiskFull))
+		assert.Equal(int64(5000), writeErr.Offset)
+	}
+	assert.Equal(5000, w.buf.Len())
+	writes := w.writes
+
+	// Encoding stops at the first failure, and the error sticks
+	assert.Equal(err, enc.Encode(root))
+	assert.Equal(writes, w.writes)
+}
+
+// TestEncodeBuffered ensures that the output is written in chunks
+func TestEncodeBuffered(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	for i := 0; i < 100; i++ {
+		root.AddChild("node", &Node{Data: "x"})
+	}
+
+	w := &limitedWriter{n: 1 << 20}
+	assert.NoError(NewEncoder(w).Encode(root))
+	assert.Equal(1, w.writes)
+	assert.True(json.Valid(w.buf.Bytes()))
+}
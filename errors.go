@@ -0,0 +1,214 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// entityDecl matches the names of the general entities declared in the
+// internal subset of a document type
+var entityDecl = regexp.MustCompile(`<!ENTITY\s+([^\s%]+)`)
+
+// Error categories. Every error returned by this package matches one of them
+// with errors.Is, so callers can decide how to react without inspecting
+// messages.
+var (
+	ErrSyntax        = errors.New("xml2json: syntax error")
+	ErrRead          = errors.New("xml2json: read error")
+	ErrCharset       = errors.New("xml2json: unsupported charset")
+	ErrLimitExceeded = errors.New("xml2json: limit exceeded")
+	ErrWrite         = errors.New("xml2json: write error")
+	ErrPlugin        = errors.New("xml2json: plugin failure")
+	ErrUnsupported   = errors.New("xml2json: unsupported construct")
+	ErrNotFound      = errors.New("xml2json: element not found")
+)
+
+// Position locates an error in the document being converted.
+type Position struct {
+	Path   string // dotted path of the current element, e.g. "osm.node.tag"
+	Line   int    // line of the input, 0 if unknown
+	Offset int64  // byte offset of the input (of the output for a WriteError)
+}
+
+func (p Position) String() string {
+	s := fmt.Sprintf("offset %d", p.Offset)
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d, %s", p.Line, s)
+	}
+	if p.Path != "" {
+		s = fmt.Sprintf("%s, %s", p.Path, s)
+	}
+	return s
+}
+
+// A SyntaxError is returned by Decode when the document is malformed or
+// ends before all of its elements have been closed. Err is either an
+// *xml.SyntaxError, a *json.SyntaxError for JSON documents,
+// io.ErrUnexpectedEOF for JSON documents and schemas ending early, or an
+// error reporting data after the top-level value of JSON documents.
+type SyntaxError struct {
+	Position
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	msg := e.Err.Error()
+	if se, ok := e.Err.(*xml.SyntaxError); ok {
+		msg = se.Msg
+	}
+	return fmt.Sprintf("%v at %v: %s", ErrSyntax, e.Position, msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrSyntax
+}
+
+// A ReadError is returned by Decode when the underlying reader fails.
+type ReadError struct {
+	Position
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("%v at %v: %v", ErrRead, e.Position, e.Err)
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ReadError) Is(target error) bool {
+	return target == ErrRead
+}
+
+// A CharsetError is returned by Decode when the document declares an
+// encoding that cannot be converted to UTF-8.
+type CharsetError struct {
+	Position
+	Charset string
+	Err     error
+}
+
+func (e *CharsetError) Error() string {
+	return fmt.Sprintf("%v %q at %v: %v", ErrCharset, e.Charset, e.Position, e.Err)
+}
+
+func (e *CharsetError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CharsetError) Is(target error) bool {
+	return target == ErrCharset
+}
+
+// A LimitError is returned when the document exceeds one of the limits set
+// on the decoder.
+type LimitError struct {
+	Position
+	Limit string // name of the limit, e.g. "depth"
+	Max   int64  // configured maximum
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v at %v: %s is limited to %d", ErrLimitExceeded, e.Position, e.Limit, e.Max)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// A NotFoundError is returned by Decode when no element is found at the root
+// path set on the decoder (see SetRoot).
+type NotFoundError struct {
+	Position // path of the root, as set on the decoder
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%v at %v", ErrNotFound, e.Position)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// A WriteError is returned by Encode when the underlying writer fails.
+// Its offset counts the bytes successfully written.
+type WriteError struct {
+	Position
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("%v at %v: %v", ErrWrite, e.Position, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+func (e *WriteError) Is(target error) bool {
+	return target == ErrWrite
+}
+
+// A PluginError is returned when a plugin fails while processing a node.
+type PluginError struct {
+	Position
+	Plugin string
+	Err    error
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("%v: %s at %v: %v", ErrPlugin, e.Plugin, e.Position, e.Err)
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PluginError) Is(target error) bool {
+	return target == ErrPlugin
+}
+
+// An UnsupportedError is returned when the input contains a construct that
+// cannot be represented in the output.
+type UnsupportedError struct {
+	Position
+	Construct string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%v at %v: %s", ErrUnsupported, e.Position, e.Construct)
+}
+
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// newDecodeError wraps an error returned by the XML tokenizer so that callers
+// can tell malformed documents apart from failing readers. References to the
+// entities declared in the document type, which are not expanded, are
+// reported as an *UnsupportedError.
+// entityErrorPrefix starts the message of the *xml.SyntaxError reporting an
+// unknown entity reference, followed by the reference, e.g. "&foo;".
+// encoding/xml gives no other way to tell these errors apart, so
+// TestEntityErrorPrefix checks the wording.
+const entityErrorPrefix = "invalid character entity "
+
+func newDecodeError(xmlDec *xml.Decoder, elem *element, err error, entities map[string]bool) error {
+	pos := Position{Path: elem.path(), Offset: xmlDec.InputOffset()}
+	if se, ok := err.(*xml.SyntaxError); ok {
+		pos.Line = se.Line
+		if ref := strings.TrimPrefix(se.Msg, entityErrorPrefix); ref != se.Msg && entities[strings.Trim(ref, "&;")] {
+			return &UnsupportedError{Position: pos, Construct: "entity reference " + ref}
+		}
+		return &SyntaxError{Position: pos, Err: se}
+	}
+	return &ReadError{Position: pos, Err: err}
+}
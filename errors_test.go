@@ -0,0 +1,169 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type panickingPlugin struct{}
+
+func (p *panickingPlugin) AddTo(n *Node) {
+	panic("boom")
+}
+
+// TestErrorCategories ensures that every failure can be told apart with errors.Is
+func TestErrorCategories(t *testing.T) {
+	table := []struct {
+		name     string
+		in       string
+		ps       []Plugin
+		category error
+		path     string
+	}{
+		{
+			name:     "syntax",
+			in:       `<osm><node id="1"><tag></node></osm>`,
+			category: ErrSyntax,
+			path:     "osm.node.tag",
+		},
+		{
+			name:     "charset",
+			in:       `<?xml version="1.0" encoding="x-unknown"?><osm/>`,
+			category: ErrCharset,
+		},
+		{
+			name:     "plugin",
+			in:       `<osm><node/></osm>`,
+			ps:       []Plugin{WithNodes(NodePlugin("osm.node", &panickingPlugin{}))},
+			category: ErrPlugin,
+			path:     "osm.node",
+		},
+		{
+			name:     "plugin with pattern",
+			in:       `<osm><node/><way><tag/><tag/></way></osm>`,
+			ps:       []Plugin{WithNodes(NodePlugin("**.tag[1]", &panickingPlugin{}))},
+			category: ErrPlugin,
+			path:     "osm.way.tag",
+		},
+		{
+			name: "plugin rename func",
+			in:   `<osm><node/></osm>`,
+			ps: []Plugin{WithRenameFunc(func(label string) string {
+				if label == "node" {
+					panic("boom")
+				}
+				return label
+			})},
+			category: ErrPlugin,
+			path:     "osm.node",
+		},
+		{
+			name:     "unsupported",
+			in:       `<!DOCTYPE osm [<!ENTITY foo "bar">]><osm><node>&foo;</node></osm>`,
+			category: ErrUnsupported,
+			path:     "osm.node",
+		},
+		{
+			name:     "not found",
+			in:       `<osm><node/></osm>`,
+			ps:       []Plugin{WithRoot("osm.way")},
+			category: ErrNotFound,
+			path:     "osm.way",
+		},
+		{
+			name:     "undeclared entity",
+			in:       `<!DOCTYPE osm [<!ENTITY foo "bar">]><osm>&baz;</osm>`,
+			category: ErrSyntax,
+			path:     "osm",
+		},
+	}
+
+	for _, scenario := range table {
+		t.Run(scenario.name, func(t *testing.T) {
+			_, err := Convert(strings.NewReader(scenario.in), scenario.ps...)
+			assert.True(t, errors.Is(err, scenario.category), "expected %v, got %v", scenario.category, err)
+
+			for _, other := range []error{ErrSyntax, ErrRead, ErrCharset, ErrLimitExceeded, ErrWrite, ErrPlugin, ErrUnsupported, ErrNotFound} {
+				if other != scenario.category {
+					assert.False(t, errors.Is(err, other), "%v should not match %v", err, other)
+				}
+			}
+
+			switch e := err.(type) {
+			case *SyntaxError:
+				assert.Equal(t, scenario.path, e.Path)
+				assert.Equal(t, 1, e.Line)
+			case *CharsetError:
+				assert.Equal(t, "x-unknown", e.Charset)
+			case *PluginError:
+				assert.Equal(t, scenario.path, e.Path)
+				assert.Contains(t, e.Error(), "boom")
+			case *NotFoundError:
+				assert.Equal(t, scenario.path, e.Path)
+			case *UnsupportedError:
+				assert.Equal(t, scenario.path, e.Path)
+				assert.Equal(t, "entity reference &foo;", e.Construct)
+			default:
+				t.Errorf("unexpected error type %T", err)
+			}
+		})
+	}
+}
+
+// TestTypeConverterPanic ensures that a panicking type converter is reported
+// as a plugin error and stops the encoder
+func TestTypeConverterPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(`<osm><node>1</node></osm>`)).Decode(root))
+
+	var buf strings.Builder
+	enc := NewEncoder(&buf)
+	enc.SetTypeConverter(TypeConverterFunc(func(s string) string {
+		panic("boom")
+	}))
+
+	err := enc.Encode(root)
+	var perr *PluginError
+	if assert.True(errors.As(err, &perr), "expected a *PluginError, got %v", err) {
+		assert.Equal("xml2json.TypeConverterFunc", perr.Plugin)
+		assert.Contains(perr.Error(), "boom")
+	}
+	assert.Equal(err, enc.Encode(root), "the encoder stays failed")
+}
+
+// TestEntityErrorPrefix ensures that encoding/xml still reports unknown
+// entity references with the message newDecodeError relies on
+func TestEntityErrorPrefix(t *testing.T) {
+	dec := xml.NewDecoder(strings.NewReader(`<osm>&foo;</osm>`))
+	var err error
+	for err == nil {
+		_, err = dec.Token()
+	}
+
+	se, ok := err.(*xml.SyntaxError)
+	if assert.True(t, ok, "expected an *xml.SyntaxError, got %v", err) {
+		assert.Equal(t, entityErrorPrefix+"&foo;", se.Msg, "the wording of encoding/xml changed, entity references are no longer reported as unsupported")
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("offset 12", Position{Offset: 12}.String())
+	assert.Equal("osm.node, line 3, offset 12", Position{Path: "osm.node", Line: 3, Offset: 12}.String())
+}
+
+// TestUnusedEntityDeclaration ensures that declaring entities is not an error
+// as long as they are not referenced
+func TestUnusedEntityDeclaration(t *testing.T) {
+	res, err := Convert(strings.NewReader(`<!DOCTYPE a [<!ENTITY foo "bar">]><a>x &amp; y</a>`), WithCompact())
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"a":"x \u0026 y"}`+"\n", res.String())
+	}
+}
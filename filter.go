@@ -0,0 +1,165 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strings"
+)
+
+// A filter selects elements and attributes to exclude or include, see
+// Decoder.ExcludeElements.
+type filter struct {
+	source  string
+	pattern pathPattern    // nil for regular expressions
+	re      *regexp.Regexp // nil for paths
+	err     error          // set when the source is not a valid pattern
+}
+
+// ExcludeElements drops the elements and attributes matching any of the
+// patterns while decoding, along with their descendants. Patterns are
+// either:
+//
+//   - paths, see NodePlugin, e.g. "osm.node.tag" or "**.-xsi:*". A path
+//     without dots matches at any depth: "tag" is the same as "**.tag".
+//   - regular expressions enclosed in slashes, matched against the dotted
+//     path of labels, e.g. "/^osm\.node\.-(uid|user)$/"
+//
+// Attributes are matched with the attribute prefix, e.g. "-id". Patterns
+// match either the labels or the prefixed names of elements and attributes,
+// as written in the document (e.g. "xsi:type"), whatever the namespace mode.
+//
+// Excluded elements are skipped without being decoded.
+func (dec *Decoder) ExcludeElements(patterns ...string) {
+	for _, p := range patterns {
+		dec.excludes = append(dec.excludes, compileFilter(p))
+	}
+}
+
+// IncludeOnly keeps only the elements and attributes matching any of the
+// patterns (see ExcludeElements), along with their descendants. The
+// ancestors of included elements are kept, without their attributes and
+// text. Branches that cannot lead to an included element are skipped
+// without being decoded.
+func (dec *Decoder) IncludeOnly(patterns ...string) {
+	for _, p := range patterns {
+		dec.includes = append(dec.includes, compileFilter(p))
+	}
+}
+
+func compileFilter(s string) filter {
+	f := filter{source: s}
+	if len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		f.re, f.err = regexp.Compile(s[1 : len(s)-1])
+		return f
+	}
+
+	if len(splitPath(s)) == 1 && s != descendants {
+		s = descendants + "." + s
+	}
+	f.pattern, f.err = compilePath(s)
+	return f
+}
+
+// checkFilters reports the first invalid pattern as a *PluginError
+func (dec *Decoder) checkFilters() error {
+	for _, filters := range [][]filter{dec.excludes, dec.includes} {
+		for _, f := range filters {
+			if f.err != nil {
+				return &PluginError{Position: Position{Path: f.source}, Plugin: "filter", Err: f.err}
+			}
+		}
+	}
+	return nil
+}
+
+// filterElement reports whether a new element must be decoded, and sets
+// whether it is included as a whole
+func (dec *Decoder) filterElement(e *element, name xml.Name) bool {
+	if len(dec.excludes) == 0 && len(dec.includes) == 0 {
+		e.included = true
+		return true
+	}
+
+	e.qualified = dec.qualifyAs(NamespacePrefix, e, name, false)
+	paths := [][]pathElem{e.stack(), e.qualifiedStack()}
+	if matchFilters(dec.excludes, paths) {
+		return false
+	}
+
+	e.included = e.parent.included || len(dec.includes) == 0 || matchFilters(dec.includes, paths)
+	return e.included || leadsToFilters(dec.includes, paths)
+}
+
+// filterAttribute reports whether an attribute of e, with the given label
+// (including the attribute prefix), must be decoded
+func (dec *Decoder) filterAttribute(e *element, name xml.Name, label string) bool {
+	if len(dec.excludes) == 0 && len(dec.includes) == 0 {
+		return true
+	}
+
+	qualified := dec.attributePrefix + dec.qualifyAs(NamespacePrefix, e, name, true)
+	paths := [][]pathElem{
+		append(e.stack(), pathElem{label: label}),
+		append(e.qualifiedStack(), pathElem{label: qualified}),
+	}
+	if matchFilters(dec.excludes, paths) {
+		return false
+	}
+	return e.included || matchFilters(dec.includes, paths)
+}
+
+// qualifiedStack returns the prefixed names leading to the element from the
+// document root
+func (e *element) qualifiedStack() []pathElem {
+	stack := e.stack()
+	for i := len(stack) - 1; i >= 0; i, e = i-1, e.parent {
+		stack[i].label = e.qualified
+	}
+	return stack
+}
+
+// matchFilters reports whether any of the filters matches any of the paths
+func matchFilters(filters []filter, paths [][]pathElem) bool {
+	for _, f := range filters {
+		for _, path := range paths {
+			if f.re != nil && f.re.MatchString(dottedPath(path)) {
+				return true
+			}
+			if f.re == nil && f.pattern.matches(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leadsToFilters reports whether the descendants of the element found at
+// any of the paths may match any of the filters
+func leadsToFilters(filters []filter, paths [][]pathElem) bool {
+	for _, f := range filters {
+		if f.re != nil {
+			return true
+		}
+
+		for _, path := range paths {
+			st := f.pattern.start()
+			for _, e := range path {
+				if st = f.pattern.next(st, e); len(st) == 0 {
+					break
+				}
+			}
+			if len(st) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func dottedPath(path []pathElem) string {
+	labels := make([]string, len(path))
+	for i, e := range path {
+		labels[i] = e.label
+	}
+	return strings.Join(labels, ".")
+}
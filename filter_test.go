@@ -0,0 +1,74 @@
+package xml2json
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const typedDoc = `<order xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" id="1" xsi:type="Order">
+  <item sku="a" xsi:nil="false"><price>1</price><note>x</note></item>
+  <item sku="b"><price>2</price></item>
+  <meta><note>y</note></meta>
+</order>`
+
+func TestExcludeElements(t *testing.T) {
+	testCases := []struct {
+		patterns []string
+		expected string
+	}{
+		{[]string{"note"}, `{"order":{"-xsi":"http://www.w3.org/2001/XMLSchema-instance","-id":"1","-type":"Order","item":[{"-sku":"a","-nil":"false","price":"1"},{"-sku":"b","price":"2"}],"meta":""}}`},
+		{[]string{"order.meta", "-xsi:*", "-xsi"}, `{"order":{"-id":"1","item":[{"-sku":"a","price":"1","note":"x"},{"-sku":"b","price":"2"}]}}`},
+		{[]string{"order.item[0]", "order.*.note", "**.-*"}, `{"order":{"item":{"price":"2"},"meta":""}}`},
+		{[]string{`/^order\.(item|meta)$/`, `/\.-[a-z]+$/`}, `{"order":""}`},
+	}
+
+	for _, tc := range testCases {
+		res, err := Convert(strings.NewReader(typedDoc), WithExcludeElements(tc.patterns...), WithCompact())
+		if assert.NoError(t, err, "%v", tc.patterns) {
+			assert.Equal(t, tc.expected+"\n", res.String(), "%v", tc.patterns)
+		}
+	}
+}
+
+func TestIncludeOnly(t *testing.T) {
+	testCases := []struct {
+		patterns []string
+		expected string
+	}{
+		{[]string{"order.item.price"}, `{"order":{"item":[{"price":"1"},{"price":"2"}]}}`},
+		{[]string{"order.item[1]"}, `{"order":{"item":{"-sku":"b","price":"2"}}}`},
+		{[]string{"note", "-id"}, `{"order":{"-id":"1","item":{"note":"x"},"meta":{"note":"y"}}}`},
+		{[]string{"**.-xsi:*"}, `{"order":{"-type":"Order","item":{"-nil":"false"}}}`},
+		{[]string{`/\.note$/`}, `{"order":{"item":{"note":"x"},"meta":{"note":"y"}}}`},
+		{[]string{"order.unknown"}, `""`},
+	}
+
+	for _, tc := range testCases {
+		res, err := Convert(strings.NewReader(typedDoc), WithIncludeOnly(tc.patterns...), WithCompact())
+		if assert.NoError(t, err, "%v", tc.patterns) {
+			assert.Equal(t, tc.expected+"\n", res.String(), "%v", tc.patterns)
+		}
+	}
+}
+
+func TestFiltersWithNamespaces(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(soapEnvelope), WithExcludeElements("b:*", "feed"), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"Envelope":{"-soap":"http://schemas.xmlsoap.org/soap/envelope/","-a":"urn:a","-b":"urn:b","Body":{"id":"1"}}}`+"\n", res.String())
+
+	res, err = Convert(strings.NewReader(soapEnvelope), WithIncludeOnly("soap:Envelope.soap:Body.a:id"), WithNamespaceMode(NamespaceURI), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"{http://schemas.xmlsoap.org/soap/envelope/}Envelope":{"{http://schemas.xmlsoap.org/soap/envelope/}Body":{"{urn:a}id":"1"}}}`+"\n", res.String())
+}
+
+func TestFiltersInvalidPattern(t *testing.T) {
+	for _, p := range []Plugin{WithExcludeElements("a[x]"), WithIncludeOnly("/(/")} {
+		_, err := Convert(strings.NewReader(typedDoc), p)
+		assert.True(t, errors.Is(err, ErrPlugin), "expected a *PluginError, got %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package xml2json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A JSONDecoder reads JSON documents produced by the Encoder and decodes them
+// back into a Node tree.
+type JSONDecoder struct {
+	r   io.Reader
+	dec *Decoder // holds the prefixes and options set by the plugins
+}
+
+// NewJSONDecoder returns a new decoder that reads from r. It accepts the same
+// plugins as NewDecoder, so the attribute and content prefixes match the ones
+// used to produce the JSON document.
+func NewJSONDecoder(r io.Reader, plugins ...Plugin) *JSONDecoder {
+	return &JSONDecoder{r: r, dec: NewDecoder(nil, plugins...)}
+}
+
+// Decode reads the JSON document from its input and stores it in root.
+//
+// Keys starting with the attribute prefix become attributes and the content
+// key becomes the element data. A malformed document is reported as a
+// *SyntaxError, and a document that cannot be represented in XML (e.g. nested
+// arrays) as an *UnsupportedError.
+//
+// Documents written with a convention are read according to it, see
+// Convention.
+func (jd *JSONDecoder) Decode(root *Node) error {
+	jsonDec := json.NewDecoder(jd.r)
+	jsonDec.UseNumber()
+
+	// The root element and the attributes cannot be restored
+	if c := jd.dec.conv; c.dropRoot || c.dropAttributes {
+		return jd.unsupported(jsonDec, "", c.name+" convention")
+	}
+
+	t, err := jd.token(jsonDec, "")
+	if err != nil {
+		return err
+	}
+	if jd.dec.conv.jsonML {
+		err = jd.decodeJSONMLDocument(jsonDec, root, t)
+	} else if t != json.Delim('{') {
+		return jd.unsupported(jsonDec, "", "top-level value must be an object")
+	} else {
+		err = jd.decodeObject(jsonDec, root, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Nothing but spaces may follow the top-level value
+	if _, err := jsonDec.Token(); err != io.EOF {
+		pos := Position{Offset: jsonDec.InputOffset()}
+		if _, ok := err.(*json.SyntaxError); ok || err == nil {
+			if err == nil {
+				err = errTrailingData
+			}
+			return &SyntaxError{Position: pos, Err: err}
+		}
+		return &ReadError{Position: pos, Err: err}
+	}
+	return nil
+}
+
+// errTrailingData is the error of a *SyntaxError reporting values after the
+// top-level one
+var errTrailingData = errors.New("data after the top-level value")
+
+// decodeObject reads the members of an object whose opening brace has
+// already been consumed and adds them to n.
+func (jd *JSONDecoder) decodeObject(jsonDec *json.Decoder, n *Node, path string) error {
+	contentKey := jd.dec.contentKey()
+
+	for jsonDec.More() {
+		t, err := jd.token(jsonDec, path)
+		if err != nil {
+			return err
+		}
+		key := t.(string)
+
+		switch {
+		case key == contentKey:
+			if err := jd.decodeContent(jsonDec, n, join(path, key)); err != nil {
+				return err
+			}
+		case jd.dec.conv.namespaceObject && key == jd.dec.attributePrefix+xmlnsPrefix:
+			if err := jd.decodeNamespaces(jsonDec, n, join(path, key)); err != nil {
+				return err
+			}
+		case jd.isAttribute(key):
+			data, err := jd.scalar(jsonDec, join(path, key))
+			if err != nil {
+				return err
+			}
+			jd.addAttribute(n, jd.label(key), data)
+		default:
+			t, err := jd.token(jsonDec, join(path, key))
+			if err != nil {
+				return err
+			}
+			// Elements are written as objects by conventions with attributes
+			// without prefix, so scalars are attributes
+			if _, ok := t.(json.Delim); !ok && jd.dec.conv.bareAttributes {
+				jd.addAttribute(n, jd.dec.attributePrefix+jd.label(key), scalarString(t))
+				continue
+			}
+			if err := jd.decodeToken(jsonDec, t, n, jd.label(key), join(path, key), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing brace
+	_, err := jd.token(jsonDec, path)
+	return err
+}
+
+// addAttribute adds an attribute to n, unless excluded
+func (jd *JSONDecoder) addAttribute(n *Node, label, data string) {
+	if _, ok := jd.dec.excludeAttrs[strings.TrimPrefix(label, jd.dec.attributePrefix)]; !ok {
+		n.AddChild(label, &Node{Data: data})
+	}
+}
+
+// decodeNamespaces reads the namespace declarations grouped in an object,
+// the default namespace under the content key (see BadgerFish)
+func (jd *JSONDecoder) decodeNamespaces(jsonDec *json.Decoder, n *Node, path string) error {
+	t, err := jd.token(jsonDec, path)
+	if err != nil {
+		return err
+	}
+	if t != json.Delim('{') {
+		return jd.unsupported(jsonDec, path, "namespace declarations must be an object")
+	}
+
+	for jsonDec.More() {
+		t, err := jd.token(jsonDec, path)
+		if err != nil {
+			return err
+		}
+		prefix := t.(string)
+		uri, err := jd.scalar(jsonDec, join(path, prefix))
+		if err != nil {
+			return err
+		}
+
+		label := jd.dec.attributePrefix + xmlnsPrefix
+		if prefix != jd.dec.contentKey() {
+			label = jd.dec.attributePrefix + qualifiedName(xmlnsPrefix, prefix)
+		}
+		n.AddChild(label, &Node{Data: uri, Space: xmlnsURL})
+	}
+
+	// Consume the closing brace
+	_, err = jd.token(jsonDec, path)
+	return err
+}
+
+// label returns the label of a key, i.e. the prefixed name of conventions
+// replacing the colon (see GData)
+func (jd *JSONDecoder) label(key string) string {
+	if sep := jd.dec.conv.keySeparator; sep != "" {
+		return strings.Replace(key, sep, ":", 1)
+	}
+	return key
+}
+
+// decodeValue reads the next value and adds it to parent under the given label
+func (jd *JSONDecoder) decodeValue(jsonDec *json.Decoder, parent *Node, label, path string, inArray bool) error {
+	t, err := jd.token(jsonDec, path)
+	if err != nil {
+		return err
+	}
+	return jd.decodeToken(jsonDec, t, parent, label, path, inArray)
+}
+
+// decodeToken decodes the value starting with the token t, already read
+func (jd *JSONDecoder) decodeToken(jsonDec *json.Decoder, t json.Token, parent *Node, label, path string, inArray bool) error {
+	switch t {
+	case json.Delim('{'):
+		n := &Node{}
+		if err := jd.decodeObject(jsonDec, n, path); err != nil {
+			return err
+		}
+		parent.AddChild(label, n)
+	case json.Delim('['):
+		if inArray {
+			return jd.unsupported(jsonDec, path, "nested array")
+		}
+		for jsonDec.More() {
+			if err := jd.decodeValue(jsonDec, parent, label, path, true); err != nil {
+				return err
+			}
+		}
+		// Consume the closing bracket
+		if _, err := jd.token(jsonDec, path); err != nil {
+			return err
+		}
+	default:
+		parent.AddChild(label, &Node{Data: scalarString(t)})
+	}
+
+	return nil
+}
+
+// decodeContent reads the content of n, either a scalar or an array of runs
+// of text and single-member objects holding the child elements (see
+// MixedOrdered)
+func (jd *JSONDecoder) decodeContent(jsonDec *json.Decoder, n *Node, path string) error {
+	t, err := jd.token(jsonDec, path)
+	if err != nil {
+		return err
+	}
+	switch t {
+	case json.Delim('{'):
+		return jd.unsupported(jsonDec, path, "content value must be a scalar or an array")
+	case json.Delim('['):
+	default:
+		n.Data = scalarString(t)
+		return nil
+	}
+
+	var texts []string
+	for jsonDec.More() {
+		t, err := jd.token(jsonDec, path)
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('['):
+			return jd.unsupported(jsonDec, path, "nested array")
+		case json.Delim('{'):
+			elements := &Node{}
+			if err := jd.decodeObject(jsonDec, elements, path); err != nil {
+				return err
+			}
+			for _, label := range elements.Labels() {
+				for _, c := range elements.Children[label] {
+					n.AddChild(label, c)
+					n.Segments = append(n.Segments, Segment{Label: label, Node: c})
+				}
+			}
+		default:
+			text := scalarString(t)
+			texts = append(texts, text)
+			n.Segments = append(n.Segments, Segment{Text: text})
+		}
+	}
+	n.Data = trimNonGraphic(strings.Join(texts, ""))
+
+	// Consume the closing bracket
+	_, err = jd.token(jsonDec, path)
+	return err
+}
+
+// scalar reads the next value, which must not be an object or an array
+func (jd *JSONDecoder) scalar(jsonDec *json.Decoder, path string) (string, error) {
+	t, err := jd.token(jsonDec, path)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := t.(json.Delim); ok {
+		return "", jd.unsupported(jsonDec, path, "attribute value must be a scalar")
+	}
+	return scalarString(t), nil
+}
+
+func (jd *JSONDecoder) token(jsonDec *json.Decoder, path string) (json.Token, error) {
+	t, err := jsonDec.Token()
+	if err != nil {
+		pos := Position{Path: path, Offset: jsonDec.InputOffset()}
+		if _, ok := err.(*json.SyntaxError); ok {
+			return nil, &SyntaxError{Position: pos, Err: err}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// The document ended before the top-level object was closed
+			return nil, &SyntaxError{Position: pos, Err: io.ErrUnexpectedEOF}
+		}
+		return nil, &ReadError{Position: pos, Err: err}
+	}
+	return t, nil
+}
+
+func (jd *JSONDecoder) unsupported(jsonDec *json.Decoder, path, construct string) error {
+	return &UnsupportedError{
+		Position:  Position{Path: path, Offset: jsonDec.InputOffset()},
+		Construct: construct,
+	}
+}
+
+func (jd *JSONDecoder) isAttribute(key string) bool {
+	return jd.dec.attributePrefix != "" && strings.HasPrefix(key, jd.dec.attributePrefix)
+}
+
+// scalarString returns the XML text of a JSON scalar. Null becomes an empty string.
+func scalarString(t json.Token) string {
+	switch v := t.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
+
+// join appends a label to a dotted path
+func join(path, label string) string {
+	if path == "" {
+		return label
+	}
+	return path + "." + label
+}
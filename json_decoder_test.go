@@ -0,0 +1,90 @@
+package xml2json
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJSONDecode ensures that attributes, content and arrays are decoded
+func TestJSONDecode(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewJSONDecoder(strings.NewReader(`{
+	  "osm": {
+	    "-version": 0.6,
+	    "-visible": true,
+	    "-user": null,
+	    "node": [{"-id": "1"}, {"-id": "2", "tag": "a"}],
+	    "mixed": {"-attr": "attribute", "#content": "content"}
+	  }
+	}`)).Decode(root)
+	assert.NoError(err)
+
+	assert.Equal("0.6", root.GetChild("osm.-version").Data)
+	assert.Equal("true", root.GetChild("osm.-visible").Data)
+	assert.Equal("", root.GetChild("osm.-user").Data)
+	assert.Len(root.GetChild("osm").Children["node"], 2)
+	assert.Equal("2", root.GetChild("osm").Children["node"][1].GetChild("-id").Data)
+	assert.Equal("content", root.GetChild("osm.mixed").Data)
+	assert.Equal("attribute", root.GetChild("osm.mixed.-attr").Data)
+	assert.Equal([]string{"-version", "-visible", "-user", "node", "mixed"}, root.GetChild("osm").Labels())
+}
+
+func TestJSONDecodeWithPlugins(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	err := NewJSONDecoder(strings.NewReader(`{"osm": {"@version": "0.6", "@generator": "x", "_content": "text"}}`),
+		WithAttrPrefix("@"), WithContentPrefix("_"), ExcludeAttributes([]string{"generator"})).Decode(root)
+	assert.NoError(err)
+
+	assert.Equal("0.6", root.GetChild("osm.@version").Data)
+	assert.Nil(root.GetChild("osm.@generator"))
+	assert.Equal("text", root.GetChild("osm").Data)
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	table := []struct {
+		in       string
+		category error
+	}{
+		{in: `{"osm": {"node": }}`, category: ErrSyntax},
+		{in: `{"osm": {"node": "1"}`, category: ErrSyntax},
+		{in: `["osm"]`, category: ErrUnsupported},
+		{in: `{"osm": {"node": [[1, 2]]}}`, category: ErrUnsupported},
+		{in: `{"osm": {"-version": {"major": 0}}}`, category: ErrUnsupported},
+	}
+
+	for _, scenario := range table {
+		err := NewJSONDecoder(strings.NewReader(scenario.in)).Decode(&Node{})
+		assert.True(t, errors.Is(err, scenario.category), "%s: expected %v, got %v", scenario.in, scenario.category, err)
+	}
+}
+
+// TestJSONDecodeTrailingData ensures that nothing but spaces may follow the
+// top-level value
+func TestJSONDecodeTrailingData(t *testing.T) {
+	for _, in := range []string{`{"a":"x"} trailing`, `{"a":"x"} {"b":"y"}`, `{"a":"x"}}`, `{"a":"x"} 1`} {
+		err := NewJSONDecoder(strings.NewReader(in)).Decode(&Node{})
+		var syntaxErr *SyntaxError
+		assert.True(t, errors.As(err, &syntaxErr), "%s: expected a *SyntaxError, got %v", in, err)
+	}
+
+	assert.NoError(t, NewJSONDecoder(strings.NewReader("{\"a\":\"x\"}\n\t ")).Decode(&Node{}))
+}
+
+// TestJSONDecodeEmpty ensures that documents ending before any value are
+// syntax errors wrapping io.ErrUnexpectedEOF
+func TestJSONDecodeEmpty(t *testing.T) {
+	err := NewJSONDecoder(strings.NewReader(" ")).Decode(&Node{})
+
+	var syntaxErr *SyntaxError
+	if assert.True(t, errors.As(err, &syntaxErr), "expected a *SyntaxError, got %v", err) {
+		assert.Equal(t, io.ErrUnexpectedEOF, syntaxErr.Err)
+	}
+}
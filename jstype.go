@@ -1,10 +1,16 @@
 package xml2json
 
 import (
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// jsonNumber matches the numbers as written in JSON, rejecting the forms
+// accepted by strconv only such as "+1", ".5", "1." or "0x1p-2"
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
 // https://cswr.github.io/JsonSchema/spec/basic_types/
 // JSType is a JavaScript extracted from a string
 type JSType int
@@ -38,13 +44,36 @@ func Str2JSType(s string) JSType {
 	return output
 }
 
+// convertAs returns the JSON encoding of s as the given type, or as a string
+// if s cannot be represented in that type
+func convertAs(s string, t JSType) string {
+	v := strings.TrimSpace(s)
+	switch t {
+	case Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return strconv.FormatBool(b)
+		}
+	case Int:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+	case Float:
+		if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case Null:
+		return "null"
+	}
+	return sanitiseString(s)
+}
+
 func isBool(s string) bool {
 	return s == "true" || s == "false"
 }
 
 func isFloat(s string) bool {
 	var output = false
-	if strings.Contains(s, ".") {
+	if strings.Contains(s, ".") && jsonNumber.MatchString(s) {
 		_, err := strconv.ParseFloat(s, 64)
 		if err == nil { // the string successfully converts to a decimal
 			output = true
@@ -55,7 +84,7 @@ func isFloat(s string) bool {
 
 func isInt(s string) bool {
 	var output = false
-	if len(s) >= 1 {
+	if len(s) >= 1 && jsonNumber.MatchString(s) {
 		_, err := strconv.Atoi(s)
 		if err == nil { // the string successfully converts to an int
 			if s != "0" && s[0] == '0' {
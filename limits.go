@@ -0,0 +1,170 @@
+package xml2json
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+)
+
+// Limits bounds the resources used to decode a document, to defend against
+// hostile input. A zero value means no limit.
+//
+// The tokenizer reads a whole start tag or run of text before its attributes
+// and length can be checked, so MaxTokenBytes bounds the input read for a
+// single token. Unless set, it defaults to a bound derived from MaxTextLength
+// and MaxAttributes (see WithMaxTokenBytes). MaxBytes bounds the memory used
+// by the whole document.
+type Limits struct {
+	MaxDepth      int64 // nesting depth of elements
+	MaxBytes      int64 // size of the input, in bytes
+	MaxAttributes int64 // number of attributes of a single element
+	MaxTextLength int64 // length of a single run of text or attribute value, in bytes
+	MaxElements   int64 // number of elements in the document
+	MaxTokenBytes int64 // size of a single token (start tag, run of text...) in the input, in bytes
+}
+
+const (
+	// tokenSlack is the part of the default token limit left for names,
+	// markup and the input read ahead by the tokenizer
+	tokenSlack = 64 << 10
+	// tokenEscapes is the number of bytes of input allowed for each byte of
+	// text by the default token limit, for entity and character references
+	tokenEscapes = 8
+)
+
+// tokenBytes returns the limit on the input read for a single token, 0 if
+// none
+func (l *Limits) tokenBytes() int64 {
+	if l.MaxTokenBytes > 0 {
+		return l.MaxTokenBytes
+	}
+	if l.MaxTextLength > 0 {
+		// A run of text, or a start tag with as many values as attributes
+		return tokenSlack + tokenEscapes*l.MaxTextLength*(l.MaxAttributes+1)
+	}
+	return 0
+}
+
+// limitReader fails with a *LimitError once more than max bytes have been
+// read from r
+type limitReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell an input of exactly max bytes
+	// from a longer one
+	if remaining := lr.max - lr.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.max {
+		return n - int(lr.read-lr.max), &LimitError{Limit: "bytes", Max: lr.max}
+	}
+	return n, err
+}
+
+// tokenReader fails with a *LimitError once more than max bytes have been
+// read since the last reset, i.e. while reading a single token. It buffers
+// the input itself, so that the tokenizer reads it byte by byte.
+type tokenReader struct {
+	r    *bufio.Reader
+	max  int64
+	read int64
+}
+
+func newTokenReader(r io.Reader, max int64) *tokenReader {
+	return &tokenReader{r: bufio.NewReader(r), max: max}
+}
+
+func (tr *tokenReader) ReadByte() (byte, error) {
+	if tr.read >= tr.max {
+		return 0, &LimitError{Limit: "token bytes", Max: tr.max}
+	}
+	b, err := tr.r.ReadByte()
+	if err == nil {
+		tr.read++
+	}
+	return b, err
+}
+
+// Read is used by the readers converting the charset of the input
+func (tr *tokenReader) Read(p []byte) (int, error) {
+	remaining := tr.max - tr.read
+	if remaining <= 0 {
+		return 0, &LimitError{Limit: "token bytes", Max: tr.max}
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := tr.r.Read(p)
+	tr.read += int64(n)
+	return n, err
+}
+
+// reset starts counting the bytes of a new token
+func (tr *tokenReader) reset() {
+	if tr != nil {
+		tr.read = 0
+	}
+}
+
+// skip consumes the rest of the element just started at the given depth, as
+// xml.Decoder.Skip does, while enforcing the limits on its descendants
+func (dec *Decoder) skip(xmlDec *xml.Decoder, tokens *tokenReader, depth int64, count *int64) error {
+	for start := depth; ; {
+		tokens.reset()
+		t, err := xmlDec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			*count++
+			if err := dec.limits.checkElement(depth, t, *count); err != nil {
+				return err
+			}
+		case xml.CharData:
+			if err := dec.limits.checkText(string(t)); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if depth == start {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// checkElement enforces the limits on a new element at the given depth,
+// count being the number of elements read so far (including this one)
+func (l *Limits) checkElement(depth int64, se xml.StartElement, count int64) error {
+	switch {
+	case l.MaxDepth > 0 && depth > l.MaxDepth:
+		return &LimitError{Limit: "depth", Max: l.MaxDepth}
+	case l.MaxElements > 0 && count > l.MaxElements:
+		return &LimitError{Limit: "elements", Max: l.MaxElements}
+	case l.MaxAttributes > 0 && int64(len(se.Attr)) > l.MaxAttributes:
+		return &LimitError{Limit: "attributes", Max: l.MaxAttributes}
+	}
+	for _, a := range se.Attr {
+		if err := l.checkText(a.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkText enforces the limit on a run of text or an attribute value
+func (l *Limits) checkText(s string) error {
+	if l.MaxTextLength > 0 && int64(len(s)) > l.MaxTextLength {
+		return &LimitError{Limit: "text length", Max: l.MaxTextLength}
+	}
+	return nil
+}
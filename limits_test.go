@@ -0,0 +1,112 @@
+package xml2json
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	table := []struct {
+		name   string
+		p      Plugin
+		limit  string
+		path   string
+		passes Plugin
+	}{
+		{name: "depth", p: WithMaxDepth(2), limit: "depth", path: "osm.node.tag", passes: WithMaxDepth(3)},
+		{name: "bytes", p: WithMaxBytes(int64(len(s) - 1)), limit: "bytes", passes: WithMaxBytes(int64(len(s)))},
+		{name: "attributes", p: WithMaxAttributes(8), limit: "attributes", path: "osm.node", passes: WithMaxAttributes(9)},
+		{name: "text length", p: WithMaxTextLength(12), limit: "text length", path: "osm.node", passes: WithMaxTextLength(20)},
+		{name: "elements", p: WithMaxElements(6), limit: "elements", path: "osm.node.tag", passes: WithMaxElements(8)},
+	}
+
+	for _, scenario := range table {
+		t.Run(scenario.name, func(t *testing.T) {
+			_, err := Convert(strings.NewReader(s), scenario.p)
+			assert.True(t, errors.Is(err, ErrLimitExceeded), "expected a limit error, got %v", err)
+
+			var limitErr *LimitError
+			if assert.True(t, errors.As(err, &limitErr)) {
+				assert.Equal(t, scenario.limit, limitErr.Limit)
+				if scenario.path != "" {
+					assert.Equal(t, scenario.path, limitErr.Path)
+				}
+			}
+
+			_, err = Convert(strings.NewReader(s), scenario.passes)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// TestLimitsLenient ensures that limits are enforced in lenient mode too
+func TestLimitsLenient(t *testing.T) {
+	_, err := Convert(strings.NewReader(s), WithLenientDecoding(), WithMaxBytes(100))
+	assert.True(t, errors.Is(err, ErrLimitExceeded), "expected a limit error, got %v", err)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.read += int64(n)
+	return n, err
+}
+
+// TestLimitsTokenBytes ensures that the input of a single token is bounded
+// before it is read as a whole
+func TestLimitsTokenBytes(t *testing.T) {
+	table := []struct {
+		name  string
+		doc   string
+		p     Plugin
+		limit string
+	}{
+		// The text read so far is checked before the token limit is reported
+		{name: "text", doc: "<a>" + strings.Repeat("x", 32<<20) + "</a>", p: WithMaxTextLength(16), limit: "text length"},
+		{name: "attribute", doc: `<a b="` + strings.Repeat("x", 32<<20) + `"/>`, p: WithMaxTextLength(16), limit: "token bytes"},
+		{name: "name", doc: "<a" + strings.Repeat("x", 32<<20) + "/>", p: WithMaxTokenBytes(1 << 10), limit: "token bytes"},
+	}
+
+	for _, scenario := range table {
+		t.Run(scenario.name, func(t *testing.T) {
+			r := &countingReader{r: strings.NewReader(scenario.doc)}
+			_, err := Convert(r, scenario.p)
+
+			var limitErr *LimitError
+			if assert.True(t, errors.As(err, &limitErr), "expected a limit error, got %v", err) {
+				assert.Equal(t, scenario.limit, limitErr.Limit)
+			}
+			assert.Less(t, r.read, int64(1<<20))
+		})
+	}
+
+	// The default bound leaves room for escaped text
+	doc := "<a>" + strings.Repeat("&amp;", 16) + "</a>"
+	_, err := Convert(strings.NewReader(doc), WithMaxTextLength(16))
+	assert.NoError(t, err)
+}
+
+// TestLimitsSkipped ensures that limits apply to the branches skipped by
+// filters and the root path
+func TestLimitsSkipped(t *testing.T) {
+	doc := "<a><skip>" + strings.Repeat("<x>", 50) + strings.Repeat("</x>", 50) + "</skip><b>1</b></a>"
+
+	for _, filter := range []Plugin{WithExcludeElements("a.skip"), WithIncludeOnly("a.b"), WithRoot("a.b")} {
+		for _, limit := range []Plugin{WithMaxDepth(5), WithMaxElements(3)} {
+			_, err := Convert(strings.NewReader(doc), filter, limit)
+			assert.True(t, errors.Is(err, ErrLimitExceeded), "expected a limit error, got %v", err)
+		}
+
+		_, err := Convert(strings.NewReader(doc), filter, WithMaxDepth(52), WithMaxElements(53))
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,106 @@
+package xml2json
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MixedContentMode controls how the text of elements mixing text and child
+// elements is decoded, e.g. <p>Hello <b>world</b> again</p>.
+type MixedContentMode int
+
+const (
+	// MixedLast keeps the last run of text only (default)
+	MixedLast MixedContentMode = iota
+	// MixedConcat joins all runs of text with the decoder's text separator,
+	// or as they are written when there is none
+	MixedConcat
+	// MixedOrdered keeps all runs of text and child elements in document
+	// order in Node.Segments. They are encoded as an array under the
+	// content key.
+	MixedOrdered
+
+	// mixedDocumentOrder is like MixedOrdered, but also keeps the child
+	// elements of elements without text in order (see JsonML)
+	mixedDocumentOrder MixedContentMode = -1
+)
+
+// Segment is a piece of mixed content: either a run of text or a child
+// element.
+type Segment struct {
+	Text  string
+	Label string // label of the child element, empty for text
+	Node  *Node  // child element, nil for text
+}
+
+// IsText reports whether the segment is a run of text
+func (s Segment) IsText() bool {
+	return s.Node == nil
+}
+
+// addText records a run of text read in the element
+func (e *element) addText(mode MixedContentMode, text string) {
+	switch mode {
+	case MixedConcat, MixedOrdered, mixedDocumentOrder:
+		if strings.TrimFunc(text, isBlank) != "" {
+			e.segments = append(e.segments, Segment{Text: text})
+		}
+	default:
+		e.n.Data = trimNonGraphic(text)
+	}
+}
+
+// addElement records a child element once it has been fully read
+func (e *element) addElement(mode MixedContentMode, label string, n *Node) {
+	if mode == MixedOrdered || mode == mixedDocumentOrder {
+		e.segments = append(e.segments, Segment{Label: label, Node: n})
+	}
+}
+
+// closeText sets the data of the element from the recorded runs of text
+func (e *element) closeText(mode MixedContentMode, sep string) {
+	var texts []string
+	hasElements := false
+	for _, s := range e.segments {
+		if s.IsText() {
+			texts = append(texts, s.Text)
+		} else {
+			hasElements = true
+		}
+	}
+
+	switch mode {
+	case MixedConcat:
+		if sep == "" {
+			// Without a separator the blanks between the runs are kept
+			e.n.Data = trimNonGraphic(strings.Join(texts, ""))
+			break
+		}
+		for i, t := range texts {
+			texts[i] = trimNonGraphic(t)
+		}
+		e.n.Data = strings.Join(texts, sep)
+	case MixedOrdered, mixedDocumentOrder:
+		e.n.Data = trimNonGraphic(strings.Join(texts, ""))
+		if !hasElements || (len(texts) == 0 && mode == MixedOrdered) {
+			break
+		}
+
+		// Leading and trailing blanks of the element are not part of its text
+		first, last := 0, len(e.segments)-1
+		if e.segments[first].IsText() {
+			e.segments[first].Text = strings.TrimLeftFunc(e.segments[first].Text, isBlank)
+		}
+		if e.segments[last].IsText() {
+			e.segments[last].Text = strings.TrimRightFunc(e.segments[last].Text, isBlank)
+		}
+		e.n.Segments = e.segments
+	}
+	e.segments = nil
+}
+
+// isBlank reports whether r is a space or a non graphic character, as
+// trimmed by trimNonGraphic
+func isBlank(r rune) bool {
+	return !unicode.IsGraphic(r) || unicode.IsSpace(r)
+}
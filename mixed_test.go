@@ -0,0 +1,83 @@
+package xml2json
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mixedDoc = `<doc><p class="intro">
+  Hello <b>world</b> and <i>you</i> again
+</p><title>plain</title></doc>`
+
+func TestMixedContent(t *testing.T) {
+	table := []struct {
+		name     string
+		ps       []Plugin
+		expected string
+	}{
+		{
+			name:     "last",
+			expected: `{"doc": {"p": {"#content": "again", "-class": "intro", "b": "world", "i": "you"}, "title": "plain"}}`,
+		},
+		{
+			name:     "concat",
+			ps:       []Plugin{WithTextSeparator(" ")},
+			expected: `{"doc": {"p": {"#content": "Hello and again", "-class": "intro", "b": "world", "i": "you"}, "title": "plain"}}`,
+		},
+		{
+			name:     "concat without separator",
+			ps:       []Plugin{WithMixedContent(MixedConcat)},
+			expected: `{"doc": {"p": {"#content": "Hello  and  again", "-class": "intro", "b": "world", "i": "you"}, "title": "plain"}}`,
+		},
+		{
+			name:     "separator then concat",
+			ps:       []Plugin{WithTextSeparator("|"), WithMixedContent(MixedConcat)},
+			expected: `{"doc": {"p": {"#content": "Hello|and|again", "-class": "intro", "b": "world", "i": "you"}, "title": "plain"}}`,
+		},
+		{
+			name:     "ordered",
+			ps:       []Plugin{WithMixedContent(MixedOrdered)},
+			expected: `{"doc": {"p": {"-class": "intro", "#content": ["Hello ", {"b": "world"}, " and ", {"i": "you"}, " again"]}, "title": "plain"}}`,
+		},
+	}
+
+	for _, scenario := range table {
+		t.Run(scenario.name, func(t *testing.T) {
+			res, err := Convert(strings.NewReader(mixedDoc), scenario.ps...)
+			assert.NoError(t, err)
+			assert.Equal(t, scenario.expected+"\n", res.String())
+		})
+	}
+}
+
+// TestMixedContentSegments ensures that segments keep the text and the elements in order
+func TestMixedContentSegments(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(mixedDoc), WithMixedContent(MixedOrdered)).Decode(root))
+
+	p := root.GetChild("doc.p")
+	assert.Equal("Hello  and  again", p.Data)
+	if assert.Len(p.Segments, 5) {
+		assert.True(p.Segments[0].IsText())
+		assert.Equal("b", p.Segments[1].Label)
+		assert.Same(p.Children["b"][0], p.Segments[1].Node)
+	}
+	assert.Nil(root.GetChild("doc.title").Segments, "text-only elements have no segments")
+}
+
+// TestMixedContentRoundTrip ensures that ordered mixed content is converted back to XML in order
+func TestMixedContentRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	ps := []Plugin{WithMixedContent(MixedOrdered)}
+	json, err := Convert(strings.NewReader(mixedDoc), ps...)
+	assert.NoError(err)
+
+	xml, err := ConvertJSON(json, ps...)
+	assert.NoError(err)
+	assert.Contains(xml.String(), `<p class="intro">Hello <b>world</b> and <i>you</i> again</p>`)
+}
@@ -0,0 +1,114 @@
+package xml2json
+
+import (
+	"encoding/xml"
+)
+
+// NamespaceMode controls how namespaced element and attribute names are
+// turned into labels.
+type NamespaceMode int
+
+const (
+	// NamespaceLocal keeps the local name only, e.g. "Body" (default)
+	NamespaceLocal NamespaceMode = iota
+	// NamespacePrefix keeps the prefix used in the document or the one
+	// registered for the namespace URI, e.g. "soap:Body"
+	NamespacePrefix
+	// NamespaceURI expands the prefix to the namespace URI, e.g.
+	// "{http://schemas.xmlsoap.org/soap/envelope/}Body"
+	NamespaceURI
+)
+
+const (
+	xmlnsPrefix = "xmlns"
+	xmlnsURL    = "http://www.w3.org/2000/xmlns/"
+	xmlURL      = "http://www.w3.org/XML/1998/namespace"
+)
+
+// binding is a namespace declaration in scope of an element. The default
+// namespace is bound to the empty prefix.
+type binding struct {
+	prefix string
+	uri    string
+}
+
+// bindNamespaces records the namespace declarations found in the attributes
+// of an element
+func (e *element) bindNamespaces(attrs []xml.Attr) {
+	for _, a := range attrs {
+		switch {
+		case a.Name.Space == xmlnsPrefix:
+			e.bindings = append(e.bindings, binding{prefix: a.Name.Local, uri: a.Value})
+		case a.Name.Space == "" && a.Name.Local == xmlnsPrefix:
+			e.bindings = append(e.bindings, binding{uri: a.Value})
+		}
+	}
+}
+
+// isNamespaceDecl reports whether the attribute name declares a namespace
+func isNamespaceDecl(name xml.Name) bool {
+	return name.Space == xmlnsPrefix || (name.Space == "" && name.Local == xmlnsPrefix)
+}
+
+// lookupPrefix returns the prefix bound to the given namespace URI in scope
+// of the element. The default namespace is only considered for elements, as
+// it does not apply to attributes.
+func (e *element) lookupPrefix(uri string, isAttr bool) (string, bool) {
+	if uri == xmlURL {
+		return "xml", true
+	}
+	for ; e != nil; e = e.parent {
+		for i := len(e.bindings) - 1; i >= 0; i-- {
+			b := e.bindings[i]
+			if b.uri == uri && (b.prefix != "" || !isAttr) {
+				return b.prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// qualify returns the label of an element or attribute name according to
+// the namespace mode of the decoder.
+func (dec *Decoder) qualify(e *element, name xml.Name, isAttr bool) string {
+	return dec.qualifyAs(dec.namespaceMode, e, name, isAttr)
+}
+
+// qualifyAs returns the label of an element or attribute name according to
+// the given namespace mode.
+func (dec *Decoder) qualifyAs(mode NamespaceMode, e *element, name xml.Name, isAttr bool) string {
+	// Namespace declarations
+	if isAttr && mode != NamespaceLocal && isNamespaceDecl(name) {
+		if name.Space == xmlnsPrefix {
+			return qualifiedName(xmlnsPrefix, name.Local)
+		}
+		return xmlnsPrefix
+	}
+
+	if name.Space == "" {
+		return name.Local
+	}
+
+	switch mode {
+	case NamespacePrefix:
+		if prefix, ok := dec.namespacePrefixes[name.Space]; ok {
+			return qualifiedName(prefix, name.Local)
+		}
+		if prefix, ok := e.lookupPrefix(name.Space, isAttr); ok {
+			return qualifiedName(prefix, name.Local)
+		}
+		// The prefix is not bound to any namespace, so the tokenizer left it as is
+		return qualifiedName(name.Space, name.Local)
+	case NamespaceURI:
+		return "{" + name.Space + "}" + name.Local
+	default:
+		return name.Local
+	}
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
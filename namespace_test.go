@@ -0,0 +1,89 @@
+package xml2json
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const soapEnvelope = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:a="urn:a" xmlns:b="urn:b">
+  <soap:Body>
+    <a:id>1</a:id>
+    <b:id b:ref="x">2</b:id>
+    <feed xmlns="http://www.w3.org/2005/Atom" xml:lang="en"><title>t</title></feed>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestNamespaceModes(t *testing.T) {
+	table := []struct {
+		name     string
+		ps       []Plugin
+		expected string
+	}{
+		{
+			name:     "local",
+			expected: `{"Envelope": {"-soap": "http://schemas.xmlsoap.org/soap/envelope/", "-a": "urn:a", "-b": "urn:b", "Body": {"id": ["1", {"#content": "2", "-ref": "x"}], "feed": {"-xmlns": "http://www.w3.org/2005/Atom", "-lang": "en", "title": "t"}}}}`,
+		},
+		{
+			name:     "prefix",
+			ps:       []Plugin{WithNamespaceMode(NamespacePrefix)},
+			expected: `{"soap:Envelope": {"-xmlns:soap": "http://schemas.xmlsoap.org/soap/envelope/", "-xmlns:a": "urn:a", "-xmlns:b": "urn:b", "soap:Body": {"a:id": "1", "b:id": {"#content": "2", "-b:ref": "x"}, "feed": {"-xmlns": "http://www.w3.org/2005/Atom", "-xml:lang": "en", "title": "t"}}}}`,
+		},
+		{
+			name:     "uri",
+			ps:       []Plugin{WithNamespaceMode(NamespaceURI)},
+			expected: `{"{http://schemas.xmlsoap.org/soap/envelope/}Envelope": {"-xmlns:soap": "http://schemas.xmlsoap.org/soap/envelope/", "-xmlns:a": "urn:a", "-xmlns:b": "urn:b", "{http://schemas.xmlsoap.org/soap/envelope/}Body": {"{urn:a}id": "1", "{urn:b}id": {"#content": "2", "-{urn:b}ref": "x"}, "{http://www.w3.org/2005/Atom}feed": {"-xmlns": "http://www.w3.org/2005/Atom", "-{http://www.w3.org/XML/1998/namespace}lang": "en", "{http://www.w3.org/2005/Atom}title": "t"}}}}`,
+		},
+		{
+			name: "registry",
+			ps: []Plugin{WithNamespacePrefixes(map[string]string{
+				"http://schemas.xmlsoap.org/soap/envelope/": "env",
+				"http://www.w3.org/2005/Atom":               "atom",
+			})},
+			expected: `{"env:Envelope": {"-xmlns:env": "http://schemas.xmlsoap.org/soap/envelope/", "-xmlns:a": "urn:a", "-xmlns:b": "urn:b", "env:Body": {"a:id": "1", "b:id": {"#content": "2", "-b:ref": "x"}, "atom:feed": {"-xmlns:atom": "http://www.w3.org/2005/Atom", "-xml:lang": "en", "atom:title": "t"}}}}`,
+		},
+	}
+
+	for _, scenario := range table {
+		t.Run(scenario.name, func(t *testing.T) {
+			res, err := Convert(strings.NewReader(soapEnvelope), scenario.ps...)
+			assert.NoError(t, err)
+			assert.Equal(t, scenario.expected+"\n", res.String())
+		})
+	}
+}
+
+// TestNamespaceSpace ensures that nodes carry their namespace URI
+func TestNamespaceSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(soapEnvelope)).Decode(root))
+
+	body := root.GetChild("Envelope.Body")
+	assert.Equal("http://schemas.xmlsoap.org/soap/envelope/", body.Space)
+	assert.Equal("urn:a", body.Children["id"][0].Space)
+	assert.Equal("urn:b", body.Children["id"][1].Space)
+	assert.Equal("urn:b", body.Children["id"][1].GetChild("-ref").Space)
+	assert.Equal("http://www.w3.org/2005/Atom", body.GetChild("feed.title").Space)
+}
+
+// TestNamespaceRoundTrip ensures that prefixed documents can be converted back to XML
+func TestNamespaceRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	ps := []Plugin{WithNamespaceMode(NamespacePrefix)}
+	first, err := Convert(strings.NewReader(soapEnvelope), ps...)
+	assert.NoError(err)
+
+	xml, err := ConvertJSON(first, ps...)
+	assert.NoError(err)
+
+	second, err := Convert(xml, ps...)
+	assert.NoError(err)
+
+	expected, err := Convert(strings.NewReader(soapEnvelope), ps...)
+	assert.NoError(err)
+	assert.Equal(expected.String(), second.String())
+}
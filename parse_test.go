@@ -77,3 +77,60 @@ func TestMixedParsing(t *testing.T) {
 	assert.Equal(t, "true", product.Deleted, "deleted should match")
 	assert.Equal(t, "null", product.Nullable, "nullable should match")
 }
+
+func TestConvertAs(t *testing.T) {
+	testCases := []struct {
+		s        string
+		t        JSType
+		expected string
+	}{
+		{"42", Int, "42"},
+		{" 042 ", Int, "42"},
+		{"4.2", Int, `"4.2"`},
+		{"0.6", Float, "0.6"},
+		{"54.0889580", Float, "54.088958"},
+		{"1e3", Float, "1000"},
+		{"NaN", Float, `"NaN"`},
+		{"true", Bool, "true"},
+		{"1", Bool, "true"},
+		{"yes", Bool, `"yes"`},
+		{"", Null, "null"},
+		{"01234", String, `"01234"`},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, convertAs(tc.s, tc.t), "%q", tc.s)
+	}
+}
+
+func TestTypeRules(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<osm version="0.6"><node id="42" lat="54.0889580" visible="true"><zip>01234</zip><count>3</count></node></osm>`
+
+	res, err := Convert(strings.NewReader(xml),
+		WithTypeConverter(Int, Float, Bool),
+		WithTypeRules(map[string]JSType{
+			"osm.node.-lat":  String,
+			"**.-visible":    Bool,
+			"**.zip":         String,
+			"osm.node.count": Float,
+		}),
+		WithCompact(),
+	)
+	assert.NoError(err)
+	assert.Equal(`{"osm":{"-version":0.6,"node":{"-id":42,"-lat":"54.0889580","-visible":true,"zip":"01234","count":3}}}`+"\n", res.String())
+
+	// Without a type converter, only the nodes with a rule are converted
+	res, err = Convert(strings.NewReader(xml),
+		WithTypeRules(map[string]JSType{"osm.node.-id": Int, "**.zip": Int}),
+		WithNodes(NodePlugin("osm.node", ToArray())),
+		WithCompact(),
+	)
+	assert.NoError(err)
+	assert.Equal(`{"osm":{"-version":"0.6","node":{"-id":[42],"-lat":["54.0889580"],"-visible":["true"],"zip":[1234],"count":["3"]}}}`+"\n", res.String())
+
+	// Rules apply to the text of elements with attributes
+	res, err = Convert(strings.NewReader(`<price currency="EUR">19.95</price>`), WithTypeRules(map[string]JSType{"price": Float}), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"price":{"#content":19.95,"-currency":"EUR"}}`+"\n", res.String())
+}
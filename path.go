@@ -0,0 +1,217 @@
+package xml2json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A pathPattern selects nodes by the labels leading to them from the root,
+// e.g. "osm.node[*].tag" or "**.item". Steps are separated by dots and can
+// be:
+//
+//   - a label, optionally with "*" wildcards, e.g. "node", "xsi:*"
+//   - "*", matching any single label
+//   - "**", matching any number of labels (including none)
+//
+// A label can be followed by an index, "[2]", to select a single child
+// among the ones with the same label, or "[*]" to select all of them (the
+// default). Labels holding dots, such as expanded namespaces
+// "{http://www.w3.org/2005/Atom}feed", can be written as is.
+type pathPattern []pathStep
+
+type pathStep struct {
+	name  string
+	index int // position among the children with the same label, -1 for any
+}
+
+// pathElem is a label and its position among the children with the same
+// label, as found in a document
+type pathElem struct {
+	label string
+	index int
+}
+
+// pathState is the set of steps reached after matching a list of labels
+type pathState []int
+
+const descendants = "**"
+
+// compilePath parses a path pattern
+func compilePath(s string) (pathPattern, error) {
+	var p pathPattern
+	for _, raw := range splitPath(s) {
+		step := pathStep{name: raw, index: -1}
+		if i := strings.LastIndex(raw, "["); i >= 0 && strings.HasSuffix(raw, "]") && !strings.HasSuffix(raw, "}]") {
+			step.name = raw[:i]
+			if idx := raw[i+1 : len(raw)-1]; idx != "*" {
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("xml2json: invalid index %q in path %q", idx, s)
+				}
+				step.index = n
+			}
+		}
+		if step.name == "" {
+			return nil, fmt.Errorf("xml2json: empty step in path %q", s)
+		}
+		if step.name == descendants && step.index >= 0 {
+			return nil, fmt.Errorf("xml2json: %q cannot be indexed in path %q", descendants, s)
+		}
+		p = append(p, step)
+	}
+	return p, nil
+}
+
+// splitPath splits a path on the dots that are not enclosed in braces
+func splitPath(s string) []string {
+	var steps []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '.':
+			if depth == 0 {
+				steps = append(steps, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(steps, s[start:])
+}
+
+// start returns the state before any label has been matched
+func (p pathPattern) start() pathState {
+	return p.closure(pathState{0})
+}
+
+// closure adds the states reachable by matching "**" against no label
+func (p pathPattern) closure(st pathState) pathState {
+	for i := 0; i < len(st); i++ {
+		s := st[i]
+		if s < len(p) && p[s].name == descendants && !st.has(s+1) {
+			st = append(st, s+1)
+		}
+	}
+	return st
+}
+
+// next returns the state after matching one more label
+func (p pathPattern) next(st pathState, e pathElem) pathState {
+	var next pathState
+	for _, s := range st {
+		if s == len(p) {
+			continue
+		}
+		switch step := p[s]; {
+		case step.name == descendants:
+			if !next.has(s) {
+				next = append(next, s)
+			}
+		case step.matches(e):
+			if !next.has(s + 1) {
+				next = append(next, s+1)
+			}
+		}
+	}
+	return p.closure(next)
+}
+
+// accepts reports whether the labels matched so far form a full match
+func (p pathPattern) accepts(st pathState) bool {
+	return st.has(len(p))
+}
+
+// matches reports whether the pattern matches the whole list of labels
+func (p pathPattern) matches(elems []pathElem) bool {
+	st := p.start()
+	for _, e := range elems {
+		if st = p.next(st, e); len(st) == 0 {
+			return false
+		}
+	}
+	return p.accepts(st)
+}
+
+// find returns the nodes matching the pattern, n being the root
+func (p pathPattern) find(n *Node) []*Node {
+	return p.findFrom(p.start(), n)
+}
+
+// findFrom returns the nodes matching the pattern, starting from n reached
+// with the given state. Nodes are returned in document order, once.
+func (p pathPattern) findFrom(st pathState, n *Node) []*Node {
+	var found []*Node
+	p.walkFrom(st, nil, n, func(_ []pathElem, n *Node) {
+		found = append(found, n)
+	})
+	return found
+}
+
+// walkFrom calls fn with the nodes matching the pattern and their path,
+// starting from n found at the given path and reached with the given state.
+// Nodes are visited in document order, once.
+func (p pathPattern) walkFrom(st pathState, path []pathElem, n *Node, fn func([]pathElem, *Node)) {
+	seen := map[*Node]bool{}
+
+	var walk func(st pathState, path []pathElem, n *Node)
+	walk = func(st pathState, path []pathElem, n *Node) {
+		if p.accepts(st) && !seen[n] {
+			seen[n] = true
+			fn(path, n)
+		}
+		for _, label := range n.Labels() {
+			for i, c := range n.Children[label] {
+				e := pathElem{label: label, index: i}
+				if next := p.next(st, e); len(next) > 0 {
+					walk(next, append(path[:len(path):len(path)], e), c)
+				}
+			}
+		}
+	}
+	walk(st, path, n)
+}
+
+func (st pathState) has(s int) bool {
+	for _, v := range st {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (step pathStep) matches(e pathElem) bool {
+	if step.index >= 0 && step.index != e.index {
+		return false
+	}
+	return matchGlob(step.name, e.label)
+}
+
+// matchGlob reports whether s matches the pattern, where "*" matches any
+// sequence of characters
+func matchGlob(pattern, s string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == s
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return len(s) >= len(part) && strings.HasSuffix(s, part)
+		}
+		j := strings.Index(s, part)
+		if j < 0 {
+			return false
+		}
+		s = s[j+len(part):]
+	}
+	return true
+}
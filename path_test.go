@@ -0,0 +1,109 @@
+package xml2json
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompilePath(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := compilePath("osm.node[2].*.**.xsi:*")
+	assert.NoError(err)
+	assert.Equal(pathPattern{
+		{name: "osm", index: -1},
+		{name: "node", index: 2},
+		{name: "*", index: -1},
+		{name: "**", index: -1},
+		{name: "xsi:*", index: -1},
+	}, p)
+
+	p, err = compilePath("{http://www.w3.org/2005/Atom}feed.{http://www.w3.org/2005/Atom}entry[*]")
+	assert.NoError(err)
+	assert.Equal(pathPattern{
+		{name: "{http://www.w3.org/2005/Atom}feed", index: -1},
+		{name: "{http://www.w3.org/2005/Atom}entry", index: -1},
+	}, p)
+
+	for _, invalid := range []string{"", "osm..node", "osm.node[x]", "osm.node[-1]", "**[0]"} {
+		_, err := compilePath(invalid)
+		assert.Error(err, invalid)
+	}
+}
+
+func TestPathMatches(t *testing.T) {
+	stack := []pathElem{{"osm", 0}, {"node", 2}, {"tag", 1}}
+
+	table := []struct {
+		pattern  string
+		expected bool
+	}{
+		{"osm.node.tag", true},
+		{"osm.node[2].tag", true},
+		{"osm.node[1].tag", false},
+		{"osm.node[*].tag[1]", true},
+		{"osm.*.tag", true},
+		{"osm.*", false},
+		{"**.tag", true},
+		{"**", true},
+		{"osm.**.tag", true},
+		{"osm.node.**.tag", true},
+		{"**.node", false},
+		{"o*.n*e.*g", true},
+		{"osm.node.tag.**", true},
+		{"osm.node.tag.*", false},
+	}
+
+	for _, scenario := range table {
+		p, err := compilePath(scenario.pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, scenario.expected, p.matches(stack), scenario.pattern)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(matchGlob("xsi:*", "xsi:type"))
+	assert.True(matchGlob("*:type", "xsi:type"))
+	assert.True(matchGlob("*", ""))
+	assert.True(matchGlob("a*b*c", "abbc"))
+	assert.False(matchGlob("a*b*c", "acb"))
+	assert.False(matchGlob("ab*ba", "aba"))
+	assert.False(matchGlob("xsi:*", "xs:type"))
+}
+
+// TestNodePluginWildcards ensures that node plugins apply to every matching node
+func TestNodePluginWildcards(t *testing.T) {
+	table := []struct {
+		path     string
+		expected int
+	}{
+		{path: "osm.node", expected: 3},
+		{path: "osm.node[*]", expected: 3},
+		{path: "osm.node[1]", expected: 1},
+		{path: "osm.node.tag", expected: 2},
+		{path: "**.tag", expected: 2},
+		{path: "osm.*", expected: 7},
+		{path: "**.-id", expected: 3},
+		{path: "osm.node[0].tag", expected: 0},
+	}
+
+	for _, scenario := range table {
+		count := 0
+		counter := NodeModifierFunc(func(n *Node) { count++ })
+
+		root := &Node{}
+		err := NewDecoder(strings.NewReader(s), WithNodes(NodePlugin(scenario.path, counter))).Decode(root)
+		assert.NoError(t, err)
+		assert.Equal(t, scenario.expected, count, scenario.path)
+	}
+}
+
+func TestNodePluginInvalidPath(t *testing.T) {
+	err := NewDecoder(strings.NewReader(s), WithNodes(NodePlugin("osm.node[x]", ToArray()))).Decode(&Node{})
+	assert.Error(t, err)
+	assert.IsType(t, &PluginError{}, err)
+}
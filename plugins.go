@@ -1,19 +1,45 @@
 package xml2json
 
 import (
+	"sort"
 	"strings"
 )
 
 type (
-	// an plugin is added to an encoder or/and to an decoder to allow custom functionality at runtime
-	plugin interface {
+	// A Plugin is added to an encoder or/and to a decoder to allow custom
+	// functionality at runtime.
+	//
+	// Plugins are applied in the order they are given, once, when the
+	// encoder or decoder is created: NewEncoder (and NewXMLEncoder) calls
+	// AddToEncoder and NewDecoder (and NewJSONDecoder) calls AddToDecoder.
+	// Both must return the encoder or decoder to use, usually the one they
+	// were given once configured with its Set* methods. A plugin that only
+	// affects one side returns the other one untouched.
+	Plugin interface {
 		AddToEncoder(*Encoder) *Encoder
 		AddToDecoder(*Decoder) *Decoder
 	}
-	// a type converter overides the default string sanitization for encoding json
-	encoderTypeConverter interface {
+
+	// A TypeConverter overrides the default string sanitization for encoding
+	// JSON. Convert is called for every leaf value with its JSON encoding (a
+	// quoted and escaped string) and returns the JSON to write instead. A
+	// panic stops the encoding and is reported as a *PluginError.
+	TypeConverter interface {
 		Convert(string) string
 	}
+
+	// TypeConverterFunc adapts a function to the TypeConverter interface
+	TypeConverterFunc func(string) string
+
+	// A NodeModifier modifies a decoded node, see NodePlugin. AddTo is called
+	// once the document has been decoded, or before a streamed element is
+	// handed over to its handler. A panic is reported as a *PluginError.
+	NodeModifier interface {
+		AddTo(*Node)
+	}
+
+	// NodeModifierFunc adapts a function to the NodeModifier interface
+	NodeModifierFunc func(*Node)
 	// customTypeConverter converts strings to JSON types using a best guess approach, only parses the JSON types given
 	// when initialized via WithTypeConverter
 	customTypeConverter struct {
@@ -22,24 +48,90 @@ type (
 
 	attrPrefixer    string
 	contentPrefixer string
+	contentKeyer    string
+
+	bareTexter struct{}
 
 	excluder []string
 
+	lenientDecoder struct{}
+
+	keySorter struct{}
+
+	indenter struct {
+		prefix string
+		indent string
+	}
+
+	compacter struct{}
+
+	jsonLines string
+
+	rooter string
+
+	typeRules map[string]JSType
+
+	emptyElements struct {
+		mode  EmptyMode
+		paths []string
+	}
+
+	schemaInstance struct{}
+
+	conventionPlugin struct {
+		convention Convention
+	}
+
+	schemaTyper struct {
+		schema *Schema
+	}
+
+	elementFilter struct {
+		exclude  bool
+		patterns []string
+	}
+
+	renamer struct {
+		labels map[string]string
+		funcs  []RenameFunc
+	}
+
+	limiter func(*Limits)
+
+	mixedContent struct {
+		mode   MixedContentMode
+		sep    string
+		sepSet bool
+	}
+
+	namespacer struct {
+		mode     NamespaceMode
+		prefixes map[string]string
+	}
+
 	nodesFormatter struct {
 		list []nodeFormatter
 	}
 	nodeFormatter struct {
-		path   string
-		plugin nodePlugin
-	}
-
-	nodePlugin interface {
-		AddTo(*Node)
+		path    string
+		pattern pathPattern
+		err     error // set when the path is not a valid pattern
+		plugin  NodeModifier
 	}
 
 	arrayFormatter struct{}
 )
 
+// Convert calls f(s)
+func (f TypeConverterFunc) Convert(s string) string {
+	return f(s)
+}
+
+// AddTo calls f(n)
+func (f NodeModifierFunc) AddTo(n *Node) {
+	f(n)
+}
+
 // WithTypeConverter allows customized js type conversion behavior by passing in the desired JSTypes
 func WithTypeConverter(ts ...JSType) *customTypeConverter {
 	return &customTypeConverter{parseTypes: ts}
@@ -59,7 +151,7 @@ func (tc *customTypeConverter) parseAsString(t JSType) bool {
 
 // Adds the type converter to the encoder
 func (tc *customTypeConverter) AddToEncoder(e *Encoder) *Encoder {
-	e.tc = tc
+	e.SetTypeConverter(tc)
 	return e
 }
 
@@ -75,9 +167,9 @@ func (tc *customTypeConverter) Convert(s string) string {
 	jsType := Str2JSType(s)
 	if tc.parseAsString(jsType) {
 		// add the quotes removed at the start of this func
-		s = `"` + s + `"`
+		return `"` + s + `"`
 	}
-	return s
+	return strings.TrimSpace(s)
 }
 
 // WithAttrPrefix appends the given prefix to the json output of xml attribute fields to preserve namespaces
@@ -87,7 +179,7 @@ func WithAttrPrefix(prefix string) *attrPrefixer {
 }
 
 func (a *attrPrefixer) AddToEncoder(e *Encoder) *Encoder {
-	e.attributePrefix = string((*a))
+	e.SetAttributePrefix(string((*a)))
 	return e
 }
 
@@ -103,7 +195,7 @@ func WithContentPrefix(prefix string) *contentPrefixer {
 }
 
 func (c *contentPrefixer) AddToEncoder(e *Encoder) *Encoder {
-	e.contentPrefix = string((*c))
+	e.SetContentPrefix(string((*c)))
 	return e
 }
 
@@ -127,6 +219,376 @@ func (ex *excluder) AddToDecoder(d *Decoder) *Decoder {
 	return d
 }
 
+// WithContentKey sets the whole key of the text of elements, e.g. "#text",
+// "_text" or "value", instead of the content prefix followed by "content"
+func WithContentKey(key string) *contentKeyer {
+	ck := contentKeyer(key)
+	return &ck
+}
+
+func (ck *contentKeyer) AddToEncoder(e *Encoder) *Encoder {
+	e.SetContentKey(string(*ck))
+	return e
+}
+
+func (ck *contentKeyer) AddToDecoder(d *Decoder) *Decoder {
+	d.SetContentKey(string(*ck))
+	return d
+}
+
+// WithBareText writes elements holding only text as their bare value, even
+// when the convention writes text as an object (e.g. BadgerFish)
+func WithBareText() *bareTexter {
+	return &bareTexter{}
+}
+
+func (bt *bareTexter) AddToEncoder(e *Encoder) *Encoder {
+	e.SetBareText(true)
+	return e
+}
+
+func (bt *bareTexter) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+// WithLenientDecoding makes the decoder keep the partial document read before
+// a syntax or read error instead of failing
+func WithLenientDecoding() *lenientDecoder {
+	return &lenientDecoder{}
+}
+
+func (l *lenientDecoder) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (l *lenientDecoder) AddToDecoder(d *Decoder) *Decoder {
+	d.SetLenient(true)
+	return d
+}
+
+// WithSortedKeys sorts the keys of JSON objects alphabetically instead of
+// keeping the document order
+func WithSortedKeys() *keySorter {
+	return &keySorter{}
+}
+
+func (ks *keySorter) AddToEncoder(e *Encoder) *Encoder {
+	e.SetSortKeys(true)
+	return e
+}
+
+func (ks *keySorter) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+// WithIndent indents the JSON output like json.MarshalIndent does
+func WithIndent(prefix, indent string) *indenter {
+	return &indenter{prefix: prefix, indent: indent}
+}
+
+func (in *indenter) AddToEncoder(e *Encoder) *Encoder {
+	e.SetIndent(in.prefix, in.indent)
+	return e
+}
+
+func (in *indenter) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+// WithCompact drops the spaces after ':' and ',' from the JSON output
+func WithCompact() *compacter {
+	return &compacter{}
+}
+
+func (c *compacter) AddToEncoder(e *Encoder) *Encoder {
+	e.SetCompact(true)
+	return e
+}
+
+func (c *compacter) AddToDecoder(d *Decoder) *Decoder {
+	return d
+}
+
+// WithJSONLines converts every element found at the given dotted path to a
+// compact JSON object on its own line (JSON Lines), instead of converting
+// the whole document
+func WithJSONLines(path string) *jsonLines {
+	jl := jsonLines(path)
+	return &jl
+}
+
+func (jl *jsonLines) AddToEncoder(e *Encoder) *Encoder {
+	e.SetIndent("", "")
+	e.SetCompact(true)
+	return e
+}
+
+func (jl *jsonLines) AddToDecoder(d *Decoder) *Decoder {
+	d.linesPath = string(*jl)
+	return d
+}
+
+// WithRoot converts only the first element found at the given path (e.g.
+// "soap:Envelope.soap:Body" or "**.item"), which becomes the top-level JSON
+// value. The rest of the document is skipped while decoding. If no element
+// is found, a *NotFoundError is returned.
+func WithRoot(path string) *rooter {
+	r := rooter(path)
+	return &r
+}
+
+func (r *rooter) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (r *rooter) AddToDecoder(d *Decoder) *Decoder {
+	d.SetRoot(string(*r))
+	return d
+}
+
+// WithTypeRules sets the JSON type of the nodes found at the given paths,
+// e.g. {"osm.node.-id": Int, "**.-visible": Bool, "**.zip": String}, see
+// Decoder.AddTypeRule. Rules take precedence over WithTypeConverter.
+func WithTypeRules(rules map[string]JSType) typeRules {
+	return typeRules(rules)
+}
+
+func (tr typeRules) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (tr typeRules) AddToDecoder(d *Decoder) *Decoder {
+	// Sort paths so that the rule winning over the others does not depend
+	// on the map order
+	paths := make([]string, 0, len(tr))
+	for path := range tr {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		d.AddTypeRule(path, tr[path])
+	}
+	return d
+}
+
+// WithEmptyElements sets how the empty elements are encoded, e.g. as null,
+// {} or true. Without paths, the mode applies to all the empty elements,
+// otherwise only to the ones found at the given paths, see
+// Decoder.AddEmptyRule.
+func WithEmptyElements(mode EmptyMode, paths ...string) *emptyElements {
+	return &emptyElements{mode: mode, paths: paths}
+}
+
+func (ee *emptyElements) AddToEncoder(e *Encoder) *Encoder {
+	if len(ee.paths) == 0 {
+		e.SetEmptyMode(ee.mode)
+	}
+	return e
+}
+
+func (ee *emptyElements) AddToDecoder(d *Decoder) *Decoder {
+	for _, path := range ee.paths {
+		d.AddEmptyRule(path, ee.mode)
+	}
+	return d
+}
+
+// WithSchema types the nodes according to the given XML Schema, see
+// Decoder.SetSchema
+func WithSchema(s *Schema) *schemaTyper {
+	return &schemaTyper{schema: s}
+}
+
+func (st *schemaTyper) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (st *schemaTyper) AddToDecoder(d *Decoder) *Decoder {
+	d.SetSchema(st.schema)
+	return d
+}
+
+// WithConvention maps XML to JSON according to the given convention, e.g.
+// BadgerFish or Parker. Plugins given after the convention can adjust it,
+// e.g. WithAttrPrefix.
+func WithConvention(c Convention) *conventionPlugin {
+	return &conventionPlugin{convention: c}
+}
+
+func (cp *conventionPlugin) AddToEncoder(e *Encoder) *Encoder {
+	return cp.convention.AddToEncoder(e)
+}
+
+func (cp *conventionPlugin) AddToDecoder(d *Decoder) *Decoder {
+	return cp.convention.AddToDecoder(d)
+}
+
+// WithSchemaInstance encodes elements with xsi:nil="true" as null and types
+// elements with their xsi:type, dropping both attributes, see
+// Decoder.SetSchemaInstance
+func WithSchemaInstance() *schemaInstance {
+	return &schemaInstance{}
+}
+
+func (si *schemaInstance) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (si *schemaInstance) AddToDecoder(d *Decoder) *Decoder {
+	d.SetSchemaInstance(true)
+	return d
+}
+
+// WithExcludeElements drops the elements and attributes matching any of the
+// patterns, e.g. "osm.bounds", "**.-xsi:*" or "/^osm\.node\.-u/", see
+// Decoder.ExcludeElements
+func WithExcludeElements(patterns ...string) *elementFilter {
+	return &elementFilter{exclude: true, patterns: patterns}
+}
+
+// WithIncludeOnly keeps only the elements and attributes matching any of the
+// patterns, along with their ancestors, see Decoder.IncludeOnly
+func WithIncludeOnly(patterns ...string) *elementFilter {
+	return &elementFilter{patterns: patterns}
+}
+
+func (ef *elementFilter) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (ef *elementFilter) AddToDecoder(d *Decoder) *Decoder {
+	if ef.exclude {
+		d.ExcludeElements(ef.patterns...)
+	} else {
+		d.IncludeOnly(ef.patterns...)
+	}
+	return d
+}
+
+// WithRenames renames the elements and attributes found at the given paths
+// of the document (before renaming) to the given labels, see
+// Decoder.AddRename. The attribute prefix is prepended to renamed
+// attributes.
+func WithRenames(labels map[string]string) *renamer {
+	return &renamer{labels: labels}
+}
+
+// WithRenameFunc renames all the elements and attributes with the given
+// functions, e.g. WithRenameFunc(CamelCase)
+func WithRenameFunc(fns ...RenameFunc) *renamer {
+	return &renamer{funcs: fns}
+}
+
+func (r *renamer) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (r *renamer) AddToDecoder(d *Decoder) *Decoder {
+	// Sort paths so that the first matching rename does not depend on the
+	// map order
+	paths := make([]string, 0, len(r.labels))
+	for path := range r.labels {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		d.AddRename(path, r.labels[path])
+	}
+	for _, fn := range r.funcs {
+		d.AddRenameFunc(fn)
+	}
+	return d
+}
+
+// WithMaxDepth limits the nesting depth of elements
+func WithMaxDepth(n int64) limiter {
+	return func(l *Limits) { l.MaxDepth = n }
+}
+
+// WithMaxBytes limits the size of the XML input
+func WithMaxBytes(n int64) limiter {
+	return func(l *Limits) { l.MaxBytes = n }
+}
+
+// WithMaxAttributes limits the number of attributes of an element
+func WithMaxAttributes(n int64) limiter {
+	return func(l *Limits) { l.MaxAttributes = n }
+}
+
+// WithMaxTextLength limits the length of runs of text and attribute values
+func WithMaxTextLength(n int64) limiter {
+	return func(l *Limits) { l.MaxTextLength = n }
+}
+
+// WithMaxElements limits the number of elements of the document
+func WithMaxElements(n int64) limiter {
+	return func(l *Limits) { l.MaxElements = n }
+}
+
+// WithMaxTokenBytes limits the size of the input read for a single start
+// tag, run of text, comment... before it is decoded. It defaults to 64 KiB
+// plus 8 bytes for each byte of text allowed by WithMaxTextLength, times the
+// number of attributes allowed by WithMaxAttributes plus one.
+func WithMaxTokenBytes(n int64) limiter {
+	return func(l *Limits) { l.MaxTokenBytes = n }
+}
+
+func (lim limiter) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (lim limiter) AddToDecoder(d *Decoder) *Decoder {
+	lim(&d.limits)
+	return d
+}
+
+// WithNamespaceMode keeps the namespace of element and attribute names in
+// their labels, either as prefixes or as expanded URIs
+func WithNamespaceMode(mode NamespaceMode) *namespacer {
+	return &namespacer{mode: mode}
+}
+
+// WithNamespacePrefixes prefixes element and attribute names with the prefix
+// registered for their namespace URI
+func WithNamespacePrefixes(prefixes map[string]string) *namespacer {
+	return &namespacer{mode: NamespacePrefix, prefixes: prefixes}
+}
+
+func (ns *namespacer) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (ns *namespacer) AddToDecoder(d *Decoder) *Decoder {
+	d.SetNamespaceMode(ns.mode)
+	if ns.prefixes != nil {
+		d.SetNamespacePrefixes(ns.prefixes)
+	}
+	return d
+}
+
+// WithMixedContent sets how elements mixing text and child elements are
+// decoded
+func WithMixedContent(mode MixedContentMode) *mixedContent {
+	return &mixedContent{mode: mode}
+}
+
+// WithTextSeparator joins all the runs of text of an element with sep
+func WithTextSeparator(sep string) *mixedContent {
+	return &mixedContent{mode: MixedConcat, sep: sep, sepSet: true}
+}
+
+func (mc *mixedContent) AddToEncoder(e *Encoder) *Encoder {
+	return e
+}
+
+func (mc *mixedContent) AddToDecoder(d *Decoder) *Decoder {
+	d.SetMixedContent(mc.mode)
+	if mc.sepSet {
+		d.SetTextSeparator(mc.sep)
+	}
+	return d
+}
+
 // WithNodes formats specific nodes
 func WithNodes(n ...nodeFormatter) *nodesFormatter {
 	return &nodesFormatter{list: n}
@@ -141,15 +603,28 @@ func (nf *nodesFormatter) AddToDecoder(d *Decoder) *Decoder {
 	return d
 }
 
-func NodePlugin(path string, plugin nodePlugin) nodeFormatter {
-	return nodeFormatter{path: path, plugin: plugin}
+// NodePlugin applies the modifier to every node found at the given path.
+// Paths are dotted labels which may contain wildcards and indices, e.g.
+// "osm.node.tag", "osm.node[0].tag", "osm.*.tag" or "**.tag".
+func NodePlugin(path string, plugin NodeModifier) nodeFormatter {
+	pattern, err := compilePath(path)
+	return nodeFormatter{path: path, pattern: pattern, err: err, plugin: plugin}
 }
 
 func (nf *nodeFormatter) Format(node *Node) {
-	child := node.GetChild(nf.path)
-	if child != nil {
-		nf.plugin.AddTo(child)
-	}
+	nf.formatFrom(nf.pattern.start(), nil, node, nil)
+}
+
+// formatFrom applies the modifier to the matching nodes, node being found at
+// the given path and reached with the given state of the pattern. visit (if
+// any) is called with the path of each node before it is modified.
+func (nf *nodeFormatter) formatFrom(st pathState, path []pathElem, node *Node, visit func([]pathElem)) {
+	nf.pattern.walkFrom(st, path, node, func(path []pathElem, n *Node) {
+		if visit != nil {
+			visit(path)
+		}
+		nf.plugin.AddTo(n)
+	})
 }
 
 func ToArray() *arrayFormatter {
@@ -0,0 +1,56 @@
+package xml2json_test
+
+import (
+	"strings"
+	"testing"
+
+	xj "github.com/basgys/goxml2json"
+	"github.com/stretchr/testify/assert"
+)
+
+// upperCaser is an in-house plugin written outside of the package
+type upperCaser struct {
+	path string
+}
+
+func (u *upperCaser) AddToEncoder(e *xj.Encoder) *xj.Encoder {
+	e.SetAttributePrefix("@")
+	e.SetTypeConverter(xj.TypeConverterFunc(strings.ToUpper))
+	return e
+}
+
+func (u *upperCaser) AddToDecoder(d *xj.Decoder) *xj.Decoder {
+	d.SetAttributePrefix("@")
+	d.AddNodeModifier(u.path, xj.NodeModifierFunc(func(n *xj.Node) {
+		n.ChildrenAlwaysAsArray = true
+	}))
+	return d
+}
+
+// TestCustomPlugin ensures that plugins can be written outside of the package
+func TestCustomPlugin(t *testing.T) {
+	assert := assert.New(t)
+
+	var p xj.Plugin = &upperCaser{path: "osm"}
+	res, err := xj.Convert(strings.NewReader(`<osm version="0.6"><foo>bar</foo></osm>`), p)
+	assert.NoError(err)
+	assert.Equal(`{"osm": {"@version": ["0.6"], "foo": ["BAR"]}}`+"\n", res.String())
+}
+
+// TestCustomPluginWithNodes ensures that node modifiers registered by several
+// plugins all apply, whatever their order
+func TestCustomPluginWithNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	lower := xj.WithNodes(xj.NodePlugin("osm.foo", xj.NodeModifierFunc(func(n *xj.Node) {
+		n.Data = strings.ToLower(n.Data)
+	})))
+	for _, plugins := range [][]xj.Plugin{
+		{&upperCaser{path: "osm"}, lower},
+		{lower, &upperCaser{path: "osm"}},
+	} {
+		res, err := xj.Convert(strings.NewReader(`<osm version="0.6"><foo>Bar</foo></osm>`), plugins...)
+		assert.NoError(err)
+		assert.Equal(`{"osm": {"@version": ["0.6"], "foo": ["BAR"]}}`+"\n", res.String())
+	}
+}
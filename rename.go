@@ -0,0 +1,192 @@
+package xml2json
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// A RenameFunc returns the label to use in place of the label of an element
+// or attribute, e.g. CamelCase. A panic is reported as a *PluginError.
+type RenameFunc func(string) string
+
+type rename struct {
+	path    string
+	pattern pathPattern
+	err     error // set when the path is not a valid pattern
+	label   string
+}
+
+// CamelCase renames "order-item", "ORDER_ITEM" or "OrderItem" to "orderItem".
+// The namespace of qualified labels is kept as is.
+func CamelCase(label string) string {
+	return renameLocal(label, func(local string) string {
+		words := splitWords(local)
+		if len(words) == 0 {
+			return local
+		}
+		for i, w := range words {
+			w = strings.ToLower(w)
+			if i > 0 {
+				r := []rune(w)
+				r[0] = unicode.ToUpper(r[0])
+				w = string(r)
+			}
+			words[i] = w
+		}
+		return strings.Join(words, "")
+	})
+}
+
+// SnakeCase renames "order-item", "ORDER_ITEM" or "OrderItem" to
+// "order_item". The namespace of qualified labels is kept as is.
+func SnakeCase(label string) string {
+	return renameLocal(label, func(local string) string {
+		words := splitWords(local)
+		if len(words) == 0 {
+			return local
+		}
+		return strings.ToLower(strings.Join(words, "_"))
+	})
+}
+
+// LowerCase renames "OrderItem" to "orderitem". The namespace of qualified
+// labels is kept as is.
+func LowerCase(label string) string {
+	return renameLocal(label, strings.ToLower)
+}
+
+// StripPrefix removes the given prefix from labels, e.g. StripPrefix("ns:")
+// renames "ns:item" to "item"
+func StripPrefix(prefix string) RenameFunc {
+	return func(label string) string {
+		if s := strings.TrimPrefix(label, prefix); s != "" {
+			return s
+		}
+		return label
+	}
+}
+
+// AddRename renames the elements or attributes found at the given path.
+// Unlike other paths, the path is made of the labels found in the document,
+// before any renaming, e.g. "ORDER_LIST.ORDER_ITEM" or "order.-item-id". A
+// matching rename takes precedence over the rename functions.
+func (dec *Decoder) AddRename(path string, label string) {
+	pattern, err := compilePath(path)
+	dec.renames = append(dec.renames, rename{path: path, pattern: pattern, err: err, label: label})
+}
+
+// AddRenameFunc renames the elements and attributes with fn. Rename
+// functions are applied in the order they are added, before the attribute
+// prefix is prepended. Namespace declarations are not renamed.
+func (dec *Decoder) AddRenameFunc(fn RenameFunc) {
+	dec.renameFuncs = append(dec.renameFuncs, fn)
+}
+
+// rename returns the label of an element or attribute, given its path in
+// the document before renaming
+func (dec *Decoder) rename(path []pathElem, label string) (string, error) {
+	for _, r := range dec.renames {
+		if r.err != nil {
+			return "", &PluginError{Position: Position{Path: r.path}, Plugin: "rename", Err: r.err}
+		}
+		if r.label != "" && r.pattern.matches(path) {
+			return r.label, nil
+		}
+	}
+	for _, fn := range dec.renameFuncs {
+		renamed, err := dec.renameWith(fn, path, label)
+		if err != nil {
+			return "", err
+		}
+		label = renamed
+	}
+	return label, nil
+}
+
+// renameWith applies a rename function to the label found at the given path.
+// A panicking function is reported as a *PluginError.
+func (dec *Decoder) renameWith(fn RenameFunc, path []pathElem, label string) (res string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(error)
+			if !ok {
+				perr = fmt.Errorf("%v", r)
+			}
+			err = &PluginError{Position: Position{Path: dottedPath(path)}, Plugin: fmt.Sprintf("%T", fn), Err: perr}
+		}
+	}()
+	return fn(label), nil
+}
+
+// renameElement sets the label of a new element from its label in the
+// document
+func (dec *Decoder) renameElement(e *element) error {
+	if len(dec.renames) == 0 && len(dec.renameFuncs) == 0 {
+		return nil
+	}
+
+	if e.parent.sourceCounts == nil {
+		e.parent.sourceCounts = map[string]int{}
+	}
+	index := e.parent.sourceCounts[e.label]
+	e.parent.sourceCounts[e.label] = index + 1
+
+	e.sourcePath = append(append([]pathElem{}, e.parent.sourcePath...), pathElem{label: e.label, index: index})
+
+	var err error
+	e.label, err = dec.rename(e.sourcePath, e.label)
+	return err
+}
+
+// renameAttribute returns the label of an attribute of e, without the
+// attribute prefix
+func (dec *Decoder) renameAttribute(e *element, label string) (string, error) {
+	if len(dec.renames) == 0 && len(dec.renameFuncs) == 0 {
+		return label, nil
+	}
+
+	path := append(append([]pathElem{}, e.sourcePath...), pathElem{label: dec.attributePrefix + label})
+	return dec.rename(path, label)
+}
+
+// renameLocal renames the local part of a qualified label with fn
+func renameLocal(label string, fn func(string) string) string {
+	i := strings.LastIndex(label, ":")
+	if strings.HasPrefix(label, "{") {
+		i = strings.LastIndex(label, "}")
+	}
+	return label[:i+1] + fn(label[i+1:])
+}
+
+// splitWords splits s into words on '-', '_', '.' and spaces, and on case
+// changes: "XMLHttpRequest" is split into "XML", "Http" and "Request"
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '_' || r == '.' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(word) > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return words
+}
@@ -0,0 +1,88 @@
+package xml2json
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameFuncs(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		label, camel, snake, lower string
+	}{
+		{"order-item", "orderItem", "order_item", "order-item"},
+		{"ORDER_ITEM", "orderItem", "order_item", "order_item"},
+		{"OrderItem", "orderItem", "order_item", "orderitem"},
+		{"orderItem", "orderItem", "order_item", "orderitem"},
+		{"XMLHttpRequest", "xmlHttpRequest", "xml_http_request", "xmlhttprequest"},
+		{"item2Name", "item2Name", "item2_name", "item2name"},
+		{"soap:Body-Part", "soap:bodyPart", "soap:body_part", "soap:body-part"},
+		{"{urn:a}ITEM", "{urn:a}item", "{urn:a}item", "{urn:a}item"},
+		{"_", "_", "_", "_"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(tc.camel, CamelCase(tc.label), tc.label)
+		assert.Equal(tc.snake, SnakeCase(tc.label), tc.label)
+		assert.Equal(tc.lower, LowerCase(tc.label), tc.label)
+	}
+
+	strip := StripPrefix("ns:")
+	assert.Equal("item", strip("ns:item"))
+	assert.Equal("other:item", strip("other:item"))
+	assert.Equal("ns:", strip("ns:"))
+}
+
+func TestConvertWithRenames(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<ORDER_LIST><ORDER_ITEM item-id="1" UNIT_PRICE="2"><product-name>a</product-name></ORDER_ITEM><ORDER_ITEM item-id="2"/></ORDER_LIST>`
+
+	res, err := Convert(strings.NewReader(xml), WithRenameFunc(CamelCase), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"orderList":{"orderItem":[{"-itemId":"1","-unitPrice":"2","productName":"a"},{"-itemId":"2"}]}}`+"\n", res.String())
+
+	// Explicit renames take precedence and match the labels of the document,
+	// with the attribute prefix of the decoder
+	res, err = Convert(strings.NewReader(xml),
+		WithRenames(map[string]string{
+			"ORDER_LIST.ORDER_ITEM[0]":   "first",
+			"**.ORDER_ITEM.@item-id":     "id",
+			"**.product-name":            "name",
+			"ORDER_LIST.ORDER_ITEM[1].x": "ignored",
+		}),
+		WithRenameFunc(LowerCase),
+		WithAttrPrefix("@"),
+		WithCompact(),
+	)
+	assert.NoError(err)
+	assert.Equal(`{"order_list":{"first":{"@id":"1","@unit_price":"2","name":"a"},"order_item":{"@id":"2"}}}`+"\n", res.String())
+
+	// Paths of other plugins use the renamed labels
+	res, err = Convert(strings.NewReader(xml),
+		WithRenameFunc(SnakeCase),
+		WithNodes(NodePlugin("order_list.order_item[0]", ToArray())),
+		WithCompact(),
+	)
+	assert.NoError(err)
+	assert.Contains(res.String(), `"-item_id":["1"],"-unit_price":["2"],"product_name":["a"]`)
+
+	_, err = Convert(strings.NewReader(xml), WithRenames(map[string]string{"a[x]": "b"}))
+	assert.True(errors.Is(err, ErrPlugin))
+}
+
+func TestConvertWithRenamesNamespaces(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(soapEnvelope),
+		WithNamespaceMode(NamespacePrefix),
+		WithRenameFunc(StripPrefix("soap:"), CamelCase),
+		WithRoot("envelope.body"),
+		WithCompact(),
+	)
+	assert.NoError(err)
+	assert.Equal(`{"a:id":"1","b:id":{"#content":"2","-b:ref":"x"},"feed":{"-xmlns":"http://www.w3.org/2005/Atom","-xml:lang":"en","title":"t"}}`+"\n", res.String())
+}
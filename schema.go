@@ -0,0 +1,462 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const xsdURL = "http://www.w3.org/2001/XMLSchema"
+
+// maxSchemaRecursion is the number of times a recursive element or type is
+// nested in the paths typed by a schema
+const maxSchemaRecursion = 3
+
+// A Schema holds the types declared by an XML Schema (XSD), see LoadSchema.
+type Schema struct {
+	rules []schemaRule
+}
+
+// schemaRule sets the type of the element or attribute found at a path,
+// and whether it is always encoded within an array
+type schemaRule struct {
+	steps []string // local names of the elements leading to the node
+	attr  string   // name of the attribute, empty for elements
+	typed bool
+	t     JSType
+	list  bool
+	array bool
+}
+
+// LoadSchema reads an XML Schema, for use with Decoder.SetSchema. It
+// supports the elements, attributes, simple types (including lists) and
+// complex types declared in the schema itself: imported and included
+// schemas, groups and substitution groups are ignored. Recursive
+// declarations are followed up to three levels of nesting.
+//
+// A malformed schema is reported as a *SyntaxError, a failing reader as a
+// *ReadError and a document that is not a schema as an *UnsupportedError.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	var doc xsdSchema
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if se, ok := err.(*xml.SyntaxError); ok {
+			return nil, &SyntaxError{Position: Position{Line: se.Line}, Err: se}
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, &SyntaxError{Err: err}
+		}
+		return nil, &ReadError{Err: err}
+	}
+	if doc.XMLName.Local != "schema" || doc.XMLName.Space != xsdURL {
+		return nil, &UnsupportedError{Construct: "schema root element <" + doc.XMLName.Local + ">"}
+	}
+
+	c := &schemaCompiler{
+		doc:          &doc,
+		simpleTypes:  map[string]*xsdSimpleType{},
+		complexTypes: map[string]*xsdComplexType{},
+		elements:     map[string]*xsdElement{},
+		attributes:   map[string]*xsdAttribute{},
+		xsdPrefixes:  map[string]bool{},
+	}
+	c.index()
+	for i, e := range doc.Elements {
+		c.element(&doc.Elements[i], nil, false, map[string]int{"element " + e.Name: 1})
+	}
+
+	return &Schema{rules: c.rules}, nil
+}
+
+// LoadSchemaFile reads the XML Schema stored in the given file, see
+// LoadSchema.
+func LoadSchemaFile(name string) (*Schema, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, &ReadError{Err: err}
+	}
+	defer f.Close()
+
+	return LoadSchema(f)
+}
+
+// formatters returns the node formatters applying the rules of the schema,
+// attributes being labelled with the given prefix
+func (s *Schema) formatters(attributePrefix string) []nodeFormatter {
+	var formatters []nodeFormatter
+	for _, r := range s.rules {
+		r := r
+		pattern := make(pathPattern, 0, len(r.steps)+1)
+		for _, step := range r.steps {
+			pattern = append(pattern, pathStep{name: step, index: -1})
+		}
+		if r.attr != "" {
+			pattern = append(pattern, pathStep{name: attributePrefix + r.attr, index: -1})
+		}
+
+		formatters = append(formatters, nodeFormatter{
+			path:    strings.Join(r.steps, "."),
+			pattern: pattern,
+			plugin: NodeModifierFunc(func(n *Node) {
+				switch {
+				case n.instanceTyped:
+					// The document knows better
+				case r.list:
+					n.SetListType(r.t)
+				case r.typed:
+					n.SetType(r.t)
+				}
+				if r.array {
+					n.AsArray = true
+				}
+			}),
+		})
+	}
+	return formatters
+}
+
+// SetSchema types the nodes according to the schema: elements and attributes
+// declared with a simple type (xs:int, xs:decimal, xs:boolean, lists, ...)
+// are encoded as JSON numbers, booleans or arrays, and elements that may
+// occur more than once (maxOccurs) are always encoded within an array.
+// Other values, such as xs:string or xs:dateTime, are kept as strings.
+//
+// Elements are matched by their local name, so the schema is meant to be
+// used with the NamespaceLocal mode. Types given by the document (see
+// SetSchemaInstance) and type rules take precedence over the schema, which
+// takes precedence over the type converter of the encoder.
+func (dec *Decoder) SetSchema(s *Schema) {
+	dec.schema = s
+}
+
+// schemaCompiler turns the declarations of a schema into rules
+type schemaCompiler struct {
+	doc          *xsdSchema
+	simpleTypes  map[string]*xsdSimpleType
+	complexTypes map[string]*xsdComplexType
+	elements     map[string]*xsdElement
+	attributes   map[string]*xsdAttribute
+	xsdPrefixes  map[string]bool
+	rules        []schemaRule
+}
+
+// index records the global declarations of the schema by name
+func (c *schemaCompiler) index() {
+	for _, a := range c.doc.Attrs {
+		if a.Value != xsdURL {
+			continue
+		}
+		if a.Name.Space == xmlnsPrefix {
+			c.xsdPrefixes[a.Name.Local] = true
+		} else if a.Name.Space == "" && a.Name.Local == xmlnsPrefix {
+			c.xsdPrefixes[""] = true
+		}
+	}
+	for i, st := range c.doc.SimpleTypes {
+		c.simpleTypes[st.Name] = &c.doc.SimpleTypes[i]
+	}
+	for i, ct := range c.doc.ComplexTypes {
+		c.complexTypes[ct.Name] = &c.doc.ComplexTypes[i]
+	}
+	for i, e := range c.doc.Elements {
+		c.elements[e.Name] = &c.doc.Elements[i]
+	}
+	for i, a := range c.doc.Attributes {
+		c.attributes[a.Name] = &c.doc.Attributes[i]
+	}
+}
+
+// element adds the rules of an element declared under the given path.
+// walking counts the elements and types being walked, as recursive
+// declarations would lead to infinite paths.
+func (c *schemaCompiler) element(e *xsdElement, path []string, repeated bool, walking map[string]int) {
+	repeated = repeated || isRepeated(e.MaxOccurs)
+	if e.Ref != "" {
+		_, local := splitQName(e.Ref)
+		ref, ok := c.elements[local]
+		key := "element " + local
+		if !ok || walking[key] >= maxSchemaRecursion {
+			return
+		}
+		walking[key]++
+		defer func() { walking[key]-- }()
+		e = ref
+	}
+
+	path = append(append([]string{}, path...), e.Name)
+	if repeated {
+		c.rules = append(c.rules, schemaRule{steps: path, array: true})
+	}
+
+	switch {
+	case e.SimpleType != nil:
+		c.simpleRule(path, "", e.SimpleType)
+	case e.ComplexType != nil:
+		c.complexType(e.ComplexType, path, walking)
+	case e.Type != "":
+		c.namedType(e.Type, path, walking)
+	}
+}
+
+// namedType adds the rules of an element of the given named type
+func (c *schemaCompiler) namedType(qname string, path []string, walking map[string]int) {
+	if t, list, ok := c.resolveSimple(qname, 0); ok {
+		c.addRule(path, "", t, list)
+		return
+	}
+
+	_, local := splitQName(qname)
+	ct, ok := c.complexTypes[local]
+	if !ok || walking[local] >= maxSchemaRecursion {
+		return
+	}
+	walking[local]++
+	c.complexType(ct, path, walking)
+	walking[local]--
+}
+
+// complexType adds the rules of the content and attributes of a complex type
+func (c *schemaCompiler) complexType(ct *xsdComplexType, path []string, walking map[string]int) {
+	c.attributeRules(ct.Attributes, path)
+	for _, g := range ct.groups() {
+		c.group(g, path, false, walking)
+	}
+
+	for _, content := range []*xsdContent{ct.SimpleContent, ct.ComplexContent} {
+		if content == nil {
+			continue
+		}
+		for _, d := range []*xsdDerivation{content.Extension, content.Restriction} {
+			if d == nil {
+				continue
+			}
+			if d.Base != "" {
+				c.namedType(d.Base, path, walking)
+			}
+			c.attributeRules(d.Attributes, path)
+			for _, g := range d.groups() {
+				c.group(g, path, false, walking)
+			}
+		}
+	}
+}
+
+// group adds the rules of the elements of a sequence, choice or all group
+func (c *schemaCompiler) group(g *xsdGroup, path []string, repeated bool, walking map[string]int) {
+	repeated = repeated || isRepeated(g.MaxOccurs)
+	for i := range g.Elements {
+		c.element(&g.Elements[i], path, repeated, walking)
+	}
+	for _, nested := range g.groups() {
+		c.group(nested, path, repeated, walking)
+	}
+}
+
+// attributeRules adds the rules of the attributes of an element
+func (c *schemaCompiler) attributeRules(attrs []xsdAttribute, path []string) {
+	for _, a := range attrs {
+		if a.Ref != "" {
+			_, local := splitQName(a.Ref)
+			ref, ok := c.attributes[local]
+			if !ok {
+				continue
+			}
+			a = *ref
+		}
+
+		switch {
+		case a.SimpleType != nil:
+			c.simpleRule(path, a.Name, a.SimpleType)
+		case a.Type != "":
+			if t, list, ok := c.resolveSimple(a.Type, 0); ok {
+				c.addRule(path, a.Name, t, list)
+			}
+		}
+	}
+}
+
+// simpleRule adds the rule of an element or attribute of an anonymous
+// simple type
+func (c *schemaCompiler) simpleRule(path []string, attr string, st *xsdSimpleType) {
+	if t, list, ok := c.simpleType(st, 0); ok {
+		c.addRule(path, attr, t, list)
+	}
+}
+
+func (c *schemaCompiler) addRule(path []string, attr string, t JSType, list bool) {
+	c.rules = append(c.rules, schemaRule{steps: path, attr: attr, typed: true, t: t, list: list})
+}
+
+// resolveSimple returns the JSON type of a built-in or declared simple
+// type, and whether it is a list
+func (c *schemaCompiler) resolveSimple(qname string, depth int) (JSType, bool, bool) {
+	prefix, local := splitQName(qname)
+	if c.xsdPrefixes[prefix] {
+		return builtinType(local), false, true
+	}
+	st, ok := c.simpleTypes[local]
+	if !ok {
+		return String, false, false
+	}
+	return c.simpleType(st, depth+1)
+}
+
+// simpleType returns the JSON type of a simple type, and whether it is a list
+func (c *schemaCompiler) simpleType(st *xsdSimpleType, depth int) (JSType, bool, bool) {
+	// Types derived from themselves are invalid
+	if depth > len(c.simpleTypes) {
+		return String, false, false
+	}
+
+	switch {
+	case st.Restriction != nil && st.Restriction.SimpleType != nil:
+		return c.simpleType(st.Restriction.SimpleType, depth+1)
+	case st.Restriction != nil:
+		return c.resolveSimple(st.Restriction.Base, depth)
+	case st.List != nil:
+		item := String
+		if st.List.SimpleType != nil {
+			item, _, _ = c.simpleType(st.List.SimpleType, depth+1)
+		} else if st.List.ItemType != "" {
+			item, _, _ = c.resolveSimple(st.List.ItemType, depth)
+		}
+		return item, true, true
+	}
+	// Unions may hold values of any of their member types
+	return String, false, true
+}
+
+// builtinType returns the JSON type of a built-in XML Schema type
+func builtinType(name string) JSType {
+	switch name {
+	case "integer", "int", "long", "short", "byte",
+		"nonNegativeInteger", "nonPositiveInteger", "positiveInteger", "negativeInteger",
+		"unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte":
+		return Int
+	case "decimal", "float", "double":
+		return Float
+	case "boolean":
+		return Bool
+	}
+	return String
+}
+
+// isRepeated reports whether a maxOccurs value allows more than one
+// occurrence
+func isRepeated(maxOccurs string) bool {
+	if maxOccurs == "unbounded" {
+		return true
+	}
+	n, err := strconv.Atoi(maxOccurs)
+	return err == nil && n > 1
+}
+
+func splitQName(qname string) (string, string) {
+	if i := strings.Index(qname, ":"); i >= 0 {
+		return qname[:i], qname[i+1:]
+	}
+	return "", qname
+}
+
+// The subset of the XML Schema vocabulary used to type nodes
+type (
+	xsdSchema struct {
+		XMLName      xml.Name
+		Attrs        []xml.Attr       `xml:",any,attr"`
+		Elements     []xsdElement     `xml:"element"`
+		Attributes   []xsdAttribute   `xml:"attribute"`
+		SimpleTypes  []xsdSimpleType  `xml:"simpleType"`
+		ComplexTypes []xsdComplexType `xml:"complexType"`
+	}
+
+	xsdElement struct {
+		Name        string          `xml:"name,attr"`
+		Type        string          `xml:"type,attr"`
+		Ref         string          `xml:"ref,attr"`
+		MaxOccurs   string          `xml:"maxOccurs,attr"`
+		SimpleType  *xsdSimpleType  `xml:"simpleType"`
+		ComplexType *xsdComplexType `xml:"complexType"`
+	}
+
+	xsdAttribute struct {
+		Name       string         `xml:"name,attr"`
+		Type       string         `xml:"type,attr"`
+		Ref        string         `xml:"ref,attr"`
+		SimpleType *xsdSimpleType `xml:"simpleType"`
+	}
+
+	xsdSimpleType struct {
+		Name        string `xml:"name,attr"`
+		Restriction *struct {
+			Base       string         `xml:"base,attr"`
+			SimpleType *xsdSimpleType `xml:"simpleType"`
+		} `xml:"restriction"`
+		List *struct {
+			ItemType   string         `xml:"itemType,attr"`
+			SimpleType *xsdSimpleType `xml:"simpleType"`
+		} `xml:"list"`
+	}
+
+	xsdComplexType struct {
+		Name           string         `xml:"name,attr"`
+		Attributes     []xsdAttribute `xml:"attribute"`
+		Sequence       *xsdGroup      `xml:"sequence"`
+		Choice         *xsdGroup      `xml:"choice"`
+		All            *xsdGroup      `xml:"all"`
+		SimpleContent  *xsdContent    `xml:"simpleContent"`
+		ComplexContent *xsdContent    `xml:"complexContent"`
+	}
+
+	xsdContent struct {
+		Extension   *xsdDerivation `xml:"extension"`
+		Restriction *xsdDerivation `xml:"restriction"`
+	}
+
+	xsdDerivation struct {
+		Base       string         `xml:"base,attr"`
+		Attributes []xsdAttribute `xml:"attribute"`
+		Sequence   *xsdGroup      `xml:"sequence"`
+		Choice     *xsdGroup      `xml:"choice"`
+		All        *xsdGroup      `xml:"all"`
+	}
+
+	xsdGroup struct {
+		MaxOccurs string       `xml:"maxOccurs,attr"`
+		Elements  []xsdElement `xml:"element"`
+		Sequences []xsdGroup   `xml:"sequence"`
+		Choices   []xsdGroup   `xml:"choice"`
+	}
+)
+
+func (ct *xsdComplexType) groups() []*xsdGroup {
+	return nonNilGroups(ct.Sequence, ct.Choice, ct.All)
+}
+
+func (d *xsdDerivation) groups() []*xsdGroup {
+	return nonNilGroups(d.Sequence, d.Choice, d.All)
+}
+
+func (g *xsdGroup) groups() []*xsdGroup {
+	var groups []*xsdGroup
+	for i := range g.Sequences {
+		groups = append(groups, &g.Sequences[i])
+	}
+	for i := range g.Choices {
+		groups = append(groups, &g.Choices[i])
+	}
+	return groups
+}
+
+func nonNilGroups(groups ...*xsdGroup) []*xsdGroup {
+	var list []*xsdGroup
+	for _, g := range groups {
+		if g != nil {
+			list = append(list, g)
+		}
+	}
+	return list
+}
@@ -0,0 +1,108 @@
+package xml2json
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const orderSchema = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="zip">
+    <xs:restriction base="xs:string"><xs:pattern value="[0-9]{5}"/></xs:restriction>
+  </xs:simpleType>
+  <xs:simpleType name="quantity">
+    <xs:restriction base="xs:positiveInteger"/>
+  </xs:simpleType>
+  <xs:simpleType name="sizes">
+    <xs:list itemType="xs:decimal"/>
+  </xs:simpleType>
+  <xs:complexType name="price">
+    <xs:simpleContent>
+      <xs:extension base="xs:decimal">
+        <xs:attribute name="currency" type="xs:string"/>
+      </xs:extension>
+    </xs:simpleContent>
+  </xs:complexType>
+  <xs:complexType name="item">
+    <xs:sequence>
+      <xs:element name="quantity" type="quantity"/>
+      <xs:element name="price" type="price"/>
+      <xs:element name="sizes" type="sizes" minOccurs="0"/>
+      <xs:element ref="item" minOccurs="0" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="gift" type="xs:boolean"/>
+  </xs:complexType>
+  <xs:element name="item" type="item"/>
+  <xs:element name="order">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="zip" type="zip"/>
+        <xs:element name="created" type="xs:dateTime"/>
+        <xs:element ref="item" maxOccurs="unbounded"/>
+      </xs:sequence>
+      <xs:attribute name="id" type="xs:int"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func TestSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	schema, err := LoadSchema(strings.NewReader(orderSchema))
+	assert.NoError(err)
+
+	xml := `<order id="7">
+  <zip>01234</zip>
+  <created>2020-01-02T03:04:05Z</created>
+  <item gift="true">
+    <quantity>2</quantity>
+    <price currency="EUR">19.90</price>
+    <sizes>38 39.5</sizes>
+    <item><quantity>1</quantity><price>5</price></item>
+  </item>
+</order>`
+
+	// Types are guessed right regardless of the sample data, even with a type
+	// converter
+	res, err := Convert(strings.NewReader(xml), WithSchema(schema), WithTypeConverter(Int, Float), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"order":{"-id":7,"zip":"01234","created":"2020-01-02T03:04:05Z","item":[{"-gift":true,"quantity":2,"price":{"#content":19.9,"-currency":"EUR"},"sizes":[38,39.5],"item":[{"quantity":1,"price":5}]}]}}`+"\n", res.String())
+
+	// Type rules take precedence over the schema
+	res, err = Convert(strings.NewReader(xml), WithSchema(schema), WithTypeRules(map[string]JSType{"**.quantity": String}), WithAttrPrefix("@"), WithCompact())
+	assert.NoError(err)
+	assert.Contains(res.String(), `"@id":7`)
+	assert.Contains(res.String(), `"quantity":"2"`)
+}
+
+func TestLoadSchemaFile(t *testing.T) {
+	assert := assert.New(t)
+
+	name := filepath.Join(t.TempDir(), "order.xsd")
+	assert.NoError(os.WriteFile(name, []byte(orderSchema), 0o600))
+
+	schema, err := LoadSchemaFile(name)
+	assert.NoError(err)
+	assert.NotEmpty(schema.rules)
+
+	_, err = LoadSchemaFile(filepath.Join(t.TempDir(), "missing.xsd"))
+	assert.True(errors.Is(err, ErrRead))
+}
+
+func TestLoadSchemaErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := LoadSchema(strings.NewReader(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">`))
+	assert.True(errors.Is(err, ErrSyntax), "got %v", err)
+
+	_, err = LoadSchema(strings.NewReader(``))
+	assert.True(errors.Is(err, ErrSyntax), "got %v", err)
+
+	_, err = LoadSchema(strings.NewReader(`<osm/>`))
+	assert.True(errors.Is(err, ErrUnsupported), "got %v", err)
+}
@@ -0,0 +1,75 @@
+package xml2json
+
+import (
+	"bytes"
+	"io"
+)
+
+type handler struct {
+	path    string
+	pattern pathPattern
+	err     error // set when the path is not a valid pattern
+	fn      func(*Node) error
+}
+
+// Handle registers fn to be called with every element found at the given
+// path (e.g. "osm.node" or "**.entry", see NodePlugin) as soon as its end tag
+// is read. Handled elements are not added to the decoded document, so their
+// memory is released once fn returns. This allows documents larger than
+// memory to be processed element by element.
+//
+// Node formatters registered for the element or its descendants are applied
+// before fn is called. An error returned by fn stops the decoding and is
+// reported as a *PluginError.
+func (dec *Decoder) Handle(path string, fn func(*Node) error) {
+	pattern, err := compilePath(path)
+	dec.handlers = append(dec.handlers, handler{path: path, pattern: pattern, err: err, fn: fn})
+}
+
+// handle calls the first handler matching the element (if any) and reports
+// whether the element has been handled.
+func (dec *Decoder) handle(elem *element) (bool, error) {
+	if len(dec.handlers) == 0 || elem.parent == nil {
+		return false, nil
+	}
+
+	stack := elem.stack()
+	for _, h := range dec.handlers {
+		if h.err != nil {
+			return false, &PluginError{Position: Position{Path: h.path}, Plugin: "handler", Err: h.err}
+		}
+		if !h.pattern.matches(stack) {
+			continue
+		}
+
+		if err := dec.format(stack, elem.n); err != nil {
+			return true, err
+		}
+		if err := h.fn(elem.n); err != nil {
+			return true, &PluginError{
+				Position: Position{Path: elem.path()},
+				Plugin:   "handler",
+				Err:      err,
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// ConvertEach converts every element found at the given path of the XML
+// document to JSON and passes it to fn, without building the whole
+// document in memory. The buffer passed to fn is only valid until fn returns.
+func ConvertEach(r io.Reader, path string, fn func(*bytes.Buffer) error, ps ...Plugin) error {
+	buf := new(bytes.Buffer)
+	dec := NewDecoder(r, ps...)
+	dec.Handle(path, func(n *Node) error {
+		buf.Reset()
+		if err := NewEncoder(buf, ps...).Encode(n); err != nil {
+			return err
+		}
+		return fn(buf)
+	})
+
+	return dec.Decode(&Node{})
+}
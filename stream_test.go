@@ -0,0 +1,76 @@
+package xml2json
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConvertEach ensures that every matching element is converted on its own
+func TestConvertEach(t *testing.T) {
+	assert := assert.New(t)
+
+	var got []string
+	err := ConvertEach(strings.NewReader(s), "osm.node", func(buf *bytes.Buffer) error {
+		got = append(got, buf.String())
+		return nil
+	}, WithNodes(NodePlugin("osm.node.tag", ToArray())))
+	assert.NoError(err)
+
+	if assert.Len(got, 3) {
+		assert.Equal(`{"-id": "298884269", "-lat": "54.0901746", "-lon": "12.2482632", "-user": "SvenHRO", "-uid": "46882", "-visible": "true", "-version": "1", "-changeset": "676636", "-timestamp": "2008-09-21T21:37:45Z"}`+"\n", got[0])
+		// Node formatters are applied relative to the streamed element
+		assert.Contains(got[2], `"tag": [{"-k": ["name"], "-v": ["Neu Broderstorf"]}, {"-k": ["traffic_sign"], "-v": ["city_limit"]}]`)
+	}
+}
+
+// TestHandle ensures that handled elements are not kept in the document
+func TestHandle(t *testing.T) {
+	assert := assert.New(t)
+
+	count := 0
+	root := &Node{}
+	dec := NewDecoder(strings.NewReader(s))
+	dec.Handle("osm.node", func(n *Node) error {
+		count++
+		assert.NotNil(n.GetChild("-id"))
+		return nil
+	})
+	assert.NoError(dec.Decode(root))
+
+	assert.Equal(3, count)
+	assert.Nil(root.GetChild("osm.node"))
+	assert.Equal("bar", root.GetChild("osm.foo").Data)
+}
+
+func TestHandleError(t *testing.T) {
+	assert := assert.New(t)
+
+	stop := errors.New("stop")
+	count := 0
+	dec := NewDecoder(strings.NewReader(s))
+	dec.Handle("osm.node", func(n *Node) error {
+		count++
+		return stop
+	})
+	err := dec.Decode(&Node{})
+
+	assert.Equal(1, count)
+	assert.True(errors.Is(err, stop))
+	assert.True(errors.Is(err, ErrPlugin))
+}
+
+// TestHandleJSONLines ensures that streamed elements can be written as JSON Lines
+func TestHandleJSONLines(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := new(bytes.Buffer)
+	dec := NewDecoder(strings.NewReader(s))
+	dec.Handle("osm.node.tag", NewJSONLinesEncoder(buf, WithIndent("", "  ")).Encode)
+	assert.NoError(dec.Decode(&Node{}))
+
+	assert.Equal(`{"-k":"name","-v":"Neu Broderstorf"}`+"\n"+`{"-k":"traffic_sign","-v":"city_limit"}`+"\n", buf.String())
+}
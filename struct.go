@@ -1,6 +1,7 @@
 package xml2json
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -9,6 +10,40 @@ type Node struct {
 	Children              map[string]Nodes
 	Data                  string
 	ChildrenAlwaysAsArray bool
+
+	// AsArray forces the node to be encoded within an array, even when it
+	// is the only child with its label
+	AsArray bool
+
+	// Space is the namespace URI of the element or attribute the node was
+	// decoded from (if any)
+	Space string
+
+	// SelfClosing is set on nodes decoded from self-closing elements, e.g.
+	// <bounds/> rather than <bounds></bounds>
+	SelfClosing bool
+
+	// Segments holds the runs of text and the child elements of a node with
+	// mixed content, in document order (see MixedOrdered)
+	Segments []Segment
+
+	// labels holds the children labels in the order they were first added
+	labels []string
+	// order holds the children in the order they were added
+	order Nodes
+
+	// jsType is the JSON type of the data, set by SetType
+	jsType JSType
+	typed  bool
+	list   bool
+
+	// instanceTyped is set when the type is given by the document itself,
+	// with xsi:type or xsi:nil
+	instanceTyped bool
+
+	// emptyMode is how the node is encoded if empty, set by SetEmptyMode
+	emptyMode EmptyMode
+	emptySet  bool
 }
 
 // Nodes is a list of nodes
@@ -21,7 +56,57 @@ func (n *Node) AddChild(s string, c *Node) {
 		n.Children = map[string]Nodes{}
 	}
 
+	if _, exists := n.Children[s]; !exists {
+		n.labels = append(n.labels, s)
+	}
 	n.Children[s] = append(n.Children[s], c)
+	n.order = append(n.order, c)
+}
+
+// Labels returns the labels of the children in the order they were first
+// added. Labels of children set directly on the Children map come last,
+// sorted alphabetically.
+func (n *Node) Labels() []string {
+	labels := make([]string, 0, len(n.Children))
+	seen := make(map[string]bool, len(n.Children))
+	for _, label := range n.labels {
+		if _, exists := n.Children[label]; exists && !seen[label] {
+			labels = append(labels, label)
+			seen[label] = true
+		}
+	}
+	if len(labels) == len(n.Children) {
+		return labels
+	}
+
+	var rest []string
+	for label := range n.Children {
+		if !seen[label] {
+			rest = append(rest, label)
+		}
+	}
+	sort.Strings(rest)
+	return append(labels, rest...)
+}
+
+// SetType sets the JSON type the data of the node is encoded as, taking
+// precedence over the type converter of the encoder. Data that cannot be
+// represented in the given type is encoded as a string.
+func (n *Node) SetType(t JSType) {
+	n.jsType = t
+	n.typed = true
+}
+
+// SetListType sets the data of the node to be encoded as an array of the
+// values separated by spaces, each of the given JSON type (see SetType)
+func (n *Node) SetListType(t JSType) {
+	n.SetType(t)
+	n.list = true
+}
+
+// Type returns the JSON type set with SetType or SetListType, if any
+func (n *Node) Type() (JSType, bool) {
+	return n.jsType, n.typed
 }
 
 // IsComplex returns whether it is a complex type (has children)
@@ -45,3 +130,14 @@ func (n *Node) GetChild(path string) *Node {
 	}
 	return result
 }
+
+// Find returns every node found at the given path, in document order. Paths
+// are dotted labels which may contain wildcards and indices, e.g.
+// "osm.node[*].tag", "osm.*.tag" or "**.tag". An invalid path finds nothing.
+func (n *Node) Find(path string) Nodes {
+	pattern, err := compilePath(path)
+	if err != nil {
+		return nil
+	}
+	return pattern.find(n)
+}
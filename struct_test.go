@@ -43,3 +43,55 @@ func TestIsComplex(t *testing.T) {
 	n.Data = "foo"
 	assert.True(n.IsComplex(), "data does not impact IsComplex")
 }
+
+func TestLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	n := Node{}
+	n.AddChild("-id", &Node{})
+	n.AddChild("name", &Node{})
+	n.AddChild("tag", &Node{})
+	n.AddChild("name", &Node{})
+	assert.Equal([]string{"-id", "name", "tag"}, n.Labels())
+
+	// Children set directly are listed last, in alphabetical order
+	n.Children["b"] = Nodes{&Node{}}
+	n.Children["a"] = Nodes{&Node{}}
+	delete(n.Children, "name")
+	assert.Equal([]string{"-id", "tag", "a", "b"}, n.Labels())
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+
+	n := Node{}
+	for _, name := range []string{"a", "b"} {
+		item := &Node{}
+		item.AddChild("name", &Node{Data: name})
+		n.AddChild("item", item)
+	}
+	n.AddChild("other", &Node{Data: "c"})
+
+	names := n.Find("item.name")
+	if assert.Len(names, 2) {
+		assert.Equal("a", names[0].Data)
+		assert.Equal("b", names[1].Data)
+	}
+	assert.Len(n.Find("item[1].name"), 1)
+	assert.Len(n.Find("*"), 3)
+	assert.Len(n.Find("**.name"), 2)
+	assert.Nil(n.Find("item[x]"))
+}
+
+func TestSetType(t *testing.T) {
+	assert := assert.New(t)
+
+	n := Node{Data: "1"}
+	_, ok := n.Type()
+	assert.False(ok)
+
+	n.SetType(Int)
+	typ, ok := n.Type()
+	assert.True(ok)
+	assert.Equal(Int, typ)
+}
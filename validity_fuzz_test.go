@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+package xml2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func FuzzConvert(f *testing.F) {
+	for _, doc := range validityDocs {
+		f.Add(doc)
+	}
+
+	f.Fuzz(func(t *testing.T, doc string) {
+		for _, ps := range validityPlugins {
+			res, err := Convert(strings.NewReader(doc), ps...)
+			if err != nil {
+				return
+			}
+			if !json.Valid(res.Bytes()) {
+				t.Fatalf("invalid JSON for %q: %s", doc, res.String())
+			}
+		}
+	})
+}
+
+func FuzzEncodeKeys(f *testing.F) {
+	f.Add("a", "-b", "c")
+	f.Add(`"`, `\`, "\x00")
+	f.Add(" ", "<&>", "\xff")
+
+	f.Fuzz(func(t *testing.T, label, attr, data string) {
+		n := &Node{Data: data}
+		n.AddChild(attr, &Node{Data: data})
+		root := &Node{}
+		root.AddChild(label, n)
+
+		for _, ps := range validityPlugins {
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf, ps...).Encode(root); err != nil {
+				t.Fatal(err)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Fatalf("invalid JSON for %q, %q, %q: %s", label, attr, data, buf.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package xml2json
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var validityDocs = []string{
+	s,
+	conventionDoc,
+	emptyDoc,
+	typedDoc,
+	`<a b="1" c='"q"'>x<d>y</d>z<d/></a>`,
+	`<a xmlns:p="urn:p" p:b="\"><p:c>&lt;&amp;&gt;</p:c></a>`,
+	`<a><b>1</b><b>2.5</b><b>true</b><b>null</b><b> </b></a>`,
+	`<a><b>+0</b><b>.5</b><b>-01</b><b>0000.</b><b>0x1.p1</b><b>&#160;1</b></a>`,
+	`<a>` + "  \x7f\t" + `</a>`,
+	`<a/>`,
+	``,
+}
+
+var validityPlugins = [][]Plugin{
+	nil,
+	{WithCompact()},
+	{WithIndent("", "  ")},
+	{WithSortedKeys(), WithIndent(" ", "\t")},
+	{WithAttrPrefix(`"`), WithContentPrefix(`\`)},
+	{WithContentKey("\"\n\\")},
+	{WithTypeConverter(Float, Int, Bool, Null)},
+	{WithMixedContent(MixedOrdered)},
+	{WithNamespaceMode(NamespaceURI)},
+	{WithRenames(map[string]string{"**.b": `b"\`}), WithRenameFunc(CamelCase)},
+	{WithEmptyElements(EmptyObject), WithEmptyElements(EmptyTrue, "**.d")},
+	{WithConvention(BadgerFish)},
+	{WithConvention(Parker)},
+	{WithConvention(GData), WithBareText()},
+	{WithConvention(JsonML)},
+}
+
+// TestEncodeValidJSON ensures that the encoder outputs valid JSON whatever
+// the document and the plugins
+func TestEncodeValidJSON(t *testing.T) {
+	for _, doc := range validityDocs {
+		for _, ps := range validityPlugins {
+			res, err := Convert(strings.NewReader(doc), ps...)
+			if assert.NoError(t, err, doc) {
+				assert.True(t, json.Valid(res.Bytes()), "invalid JSON for %q: %s", doc, res.String())
+			}
+		}
+	}
+}
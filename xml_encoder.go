@@ -0,0 +1,161 @@
+package xml2json
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// An XMLEncoder writes a Node tree as an XML document to an output stream.
+type XMLEncoder struct {
+	w       io.Writer
+	err     error
+	written int64
+	enc     *Encoder // holds the prefixes and options set by the plugins
+}
+
+// NewXMLEncoder returns a new encoder that writes to w. It accepts the same
+// plugins as NewEncoder, so children labelled with the attribute prefix are
+// written as attributes.
+func NewXMLEncoder(w io.Writer, plugins ...Plugin) *XMLEncoder {
+	return &XMLEncoder{w: w, enc: NewEncoder(nil, plugins...)}
+}
+
+// Encode writes the child of root as the XML document element to the stream.
+//
+// A root holding anything but a single element (several elements, attributes
+// or text), as well as labels that are not valid XML names, are reported as
+// an *UnsupportedError and a failing writer as a *WriteError.
+func (xe *XMLEncoder) Encode(root *Node) error {
+	if xe.err != nil {
+		return xe.err
+	}
+	if root == nil {
+		return nil
+	}
+
+	labels := root.Labels()
+	if len(labels) != 1 || len(root.Children[labels[0]]) != 1 || xe.isAttribute(labels[0]) ||
+		root.Data != "" || len(root.Segments) > 0 {
+		return &UnsupportedError{
+			Position:  Position{Offset: xe.written},
+			Construct: "document without a single root element",
+		}
+	}
+
+	label := labels[0]
+	if err := xe.element(label, root.Children[label][0], label); err != nil {
+		return err
+	}
+	xe.write("\n")
+
+	return xe.err
+}
+
+func (xe *XMLEncoder) element(label string, n *Node, path string) error {
+	if !isXMLName(label) {
+		return &UnsupportedError{
+			Position:  Position{Path: path, Offset: xe.written},
+			Construct: fmt.Sprintf("invalid element name %q", label),
+		}
+	}
+
+	xe.write("<")
+	xe.write(label)
+
+	// Attributes come first, then elements in document order
+	var elements []string
+	for _, l := range n.Labels() {
+		if !xe.isAttribute(l) {
+			elements = append(elements, l)
+			continue
+		}
+
+		name := strings.TrimPrefix(l, xe.enc.attributePrefix)
+		if !isXMLName(name) {
+			return &UnsupportedError{
+				Position:  Position{Path: join(path, l), Offset: xe.written},
+				Construct: fmt.Sprintf("invalid attribute name %q", name),
+			}
+		}
+		xe.write(" ")
+		xe.write(name)
+		xe.write(`="`)
+		xe.escape(n.Children[l][0].Data)
+		xe.write(`"`)
+	}
+
+	if len(n.Segments) > 0 {
+		// Mixed content is written in document order
+		xe.write(">")
+		for _, s := range n.Segments {
+			if s.IsText() {
+				xe.escape(s.Text)
+			} else if err := xe.element(s.Label, s.Node, join(path, s.Label)); err != nil {
+				return err
+			}
+		}
+	} else if n.Data == "" && len(elements) == 0 {
+		xe.write("/>")
+		return xe.err
+	} else {
+		xe.write(">")
+		xe.escape(n.Data)
+
+		for _, l := range elements {
+			for _, c := range n.Children[l] {
+				if err := xe.element(l, c, join(path, l)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	xe.write("</")
+	xe.write(label)
+	xe.write(">")
+
+	return xe.err
+}
+
+func (xe *XMLEncoder) isAttribute(label string) bool {
+	return xe.enc.attributePrefix != "" && strings.HasPrefix(label, xe.enc.attributePrefix)
+}
+
+func (xe *XMLEncoder) escape(s string) {
+	if xe.err != nil {
+		return
+	}
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	xe.write(b.String())
+}
+
+func (xe *XMLEncoder) write(s string) {
+	if xe.err != nil {
+		return
+	}
+	n, err := io.WriteString(xe.w, s)
+	xe.written += int64(n)
+	if err != nil {
+		xe.err = &WriteError{Position: Position{Offset: xe.written}, Err: err}
+	}
+}
+
+// isXMLName reports whether s can be used as an element or attribute name
+func isXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r), r == '_', r == ':':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package xml2json
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestXMLEncode ensures that the XML encoder writes attributes, data and children
+func TestXMLEncode(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	osm := &Node{}
+	osm.AddChild("-version", &Node{Data: "0.6"})
+	osm.AddChild("bounds", &Node{})
+	osm.AddChild("foo", &Node{Data: `bar & "baz" <qux>`})
+	node := &Node{}
+	node.AddChild("-id", &Node{Data: "1"})
+	node.AddChild("tag", &Node{Data: "a"})
+	node.AddChild("tag", &Node{Data: "b"})
+	osm.AddChild("node", node)
+	root.AddChild("osm", osm)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(NewXMLEncoder(buf).Encode(root))
+	assert.Equal(`<osm version="0.6"><bounds/><foo>bar &amp; &#34;baz&#34; &lt;qux&gt;</foo><node id="1"><tag>a</tag><tag>b</tag></node></osm>`+"\n", buf.String())
+}
+
+// TestXMLEncodeSingleRoot ensures that only documents with a single root
+// element are written
+func TestXMLEncodeSingleRoot(t *testing.T) {
+	for _, in := range []string{`{"a":"x","b":"y"}`, `{"a":["x","y"]}`, `{"-x":"1","a":"y"}`, `{"-x":"1"}`, `{"#content":"x"}`, `{}`} {
+		_, err := ConvertJSON(strings.NewReader(in))
+		var unsupportedErr *UnsupportedError
+		assert.True(t, errors.As(err, &unsupportedErr), "%s: expected an *UnsupportedError, got %v", in, err)
+	}
+
+	res, err := ConvertJSON(strings.NewReader(`{"a":{"-x":"1","b":"y"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, xml.Header+`<a x="1"><b>y</b></a>`+"\n", res.String())
+}
+
+func TestXMLEncodeInvalidName(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	root.AddChild("osm", &Node{})
+	root.Children["osm"][0].AddChild("1node", &Node{Data: "x"})
+
+	err := NewXMLEncoder(new(bytes.Buffer)).Encode(root)
+	var unsupportedErr *UnsupportedError
+	if assert.True(errors.As(err, &unsupportedErr), "expected an *UnsupportedError, got %v", err) {
+		assert.Equal("osm.1node", unsupportedErr.Path)
+	}
+}
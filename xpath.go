@@ -0,0 +1,417 @@
+package xml2json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An XPath is a compiled XPath expression that selects nodes of a decoded
+// document. It supports a practical subset of XPath 1.0:
+//
+//   - absolute and relative location paths: "/osm/node", "node/tag"
+//   - the child and descendant-or-self axes: "osm/node", "//tag", "osm//tag"
+//   - name tests, "*", "." and "@attr" or "@*" attribute tests
+//   - text() to select the text of elements
+//   - predicates: positions "[2]" and "[last()]", existence "[@id]" or
+//     "[name]", and equality "[@id='1']", "[name!='x']", "[text()='x']" or
+//     "[.='x']"
+//
+// Boolean operators such as "and" or "or", comparisons other than "=" and
+// "!=", and functions other than last() and text() are not supported.
+//
+// Absolute paths start from the node the expression is evaluated on, which
+// is usually the root returned by Decode. Nodes are selected in document
+// order.
+type XPath struct {
+	expr       string
+	steps      []xpathStep
+	attrPrefix string
+}
+
+type xpathStep struct {
+	descendants bool   // preceded by "//"
+	kind        string // "element", "attribute", "text" or "self"
+	name        string // name test, "*" for any
+	predicates  []xpathPredicate
+}
+
+type xpathPredicate struct {
+	position int  // 1-based position, 0 if not positional
+	last     bool // last()
+
+	kind   string // "attribute", "element", "text" or "self" for tests
+	name   string
+	op     string // "", "=" or "!="
+	value  string
+	exists bool // test for existence only
+}
+
+// CompileXPath parses an XPath expression. Attributes are looked up with the
+// default attribute prefix, see SetAttributePrefix. Invalid expressions and
+// expressions outside of the supported subset are reported as an
+// *UnsupportedError.
+func CompileXPath(expr string) (*XPath, error) {
+	x := &XPath{expr: expr, attrPrefix: attrPrefix}
+
+	rest := strings.TrimSpace(expr)
+	if rest == "" {
+		return nil, x.errorf("empty expression")
+	}
+	rest = strings.TrimPrefix(rest, "/")
+	descendants := false
+	if strings.HasPrefix(rest, "/") {
+		descendants = true
+		rest = rest[1:]
+	}
+
+	for {
+		raw, tail, err := x.nextStep(rest)
+		if err != nil {
+			return nil, err
+		}
+		step, err := x.parseStep(raw)
+		if err != nil {
+			return nil, err
+		}
+		step.descendants = descendants
+		x.steps = append(x.steps, step)
+
+		if tail == "" {
+			break
+		}
+		descendants = strings.HasPrefix(tail, "//")
+		rest = strings.TrimPrefix(tail[1:], "/")
+	}
+
+	return x, nil
+}
+
+// SetAttributePrefix sets the prefix of the labels of attributes, as set on
+// the decoder.
+func (x *XPath) SetAttributePrefix(prefix string) {
+	x.attrPrefix = prefix
+}
+
+// String returns the source expression
+func (x *XPath) String() string {
+	return x.expr
+}
+
+// Select returns the nodes selected by the expression, evaluated on n
+func (x *XPath) Select(n *Node) Nodes {
+	context := Nodes{n}
+	for _, step := range x.steps {
+		if step.descendants {
+			context = x.descendantsOrSelf(context)
+		}
+
+		var next Nodes
+		seen := map[*Node]bool{}
+		for _, c := range context {
+			for _, s := range x.apply(step, c) {
+				if !seen[s] {
+					seen[s] = true
+					next = append(next, s)
+				}
+			}
+		}
+		context = next
+	}
+	return context
+}
+
+// Select returns the nodes selected by the XPath expression, see XPath
+func (n *Node) Select(expr string) (Nodes, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Select(n), nil
+}
+
+// SelectOne returns the first node selected by the XPath expression, or nil
+// if there is none
+func (n *Node) SelectOne(expr string) (*Node, error) {
+	nodes, err := n.Select(expr)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// apply returns the nodes selected by a single step from the context node
+func (x *XPath) apply(step xpathStep, n *Node) Nodes {
+	var candidates Nodes
+	switch step.kind {
+	case "self":
+		candidates = Nodes{n}
+	case "text":
+		if n.Data != "" {
+			candidates = Nodes{&Node{Data: n.Data}}
+		}
+	case "attribute":
+		candidates = x.attributes(n, step.name)
+	default:
+		candidates = x.elements(n, step.name)
+	}
+
+	for _, p := range step.predicates {
+		var filtered Nodes
+		for i, c := range candidates {
+			if x.test(p, c, i+1, len(candidates)) {
+				filtered = append(filtered, c)
+			}
+		}
+		candidates = filtered
+	}
+	return candidates
+}
+
+func (x *XPath) test(p xpathPredicate, n *Node, position, size int) bool {
+	switch {
+	case p.last:
+		return position == size
+	case p.position > 0:
+		return position == p.position
+	}
+
+	var values []string
+	switch p.kind {
+	case "attribute":
+		for _, a := range x.attributes(n, p.name) {
+			values = append(values, a.Data)
+		}
+	case "element":
+		for _, e := range x.elements(n, p.name) {
+			values = append(values, e.Data)
+		}
+	default:
+		if p.kind == "self" || n.Data != "" {
+			values = []string{n.Data}
+		}
+	}
+
+	if p.exists {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if (v == p.value) == (p.op == "=") {
+			return true
+		}
+	}
+	return false
+}
+
+// elements returns the element children of n matching the name test, in
+// the order they were added. Children set directly on the Children map come
+// last, in the order of their labels.
+func (x *XPath) elements(n *Node, name string) Nodes {
+	var nodes Nodes
+	for _, label := range n.Labels() {
+		if x.isAttribute(label) || (name != "*" && name != label) {
+			continue
+		}
+		nodes = append(nodes, n.Children[label]...)
+	}
+	if name != "*" || len(nodes) < 2 {
+		return nodes
+	}
+
+	matched := make(map[*Node]bool, len(nodes))
+	for _, c := range nodes {
+		matched[c] = true
+	}
+	ordered := make(Nodes, 0, len(nodes))
+	for _, c := range n.order {
+		if matched[c] {
+			ordered = append(ordered, c)
+			delete(matched, c)
+		}
+	}
+	for _, c := range nodes {
+		if matched[c] {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered
+}
+
+// attributes returns the attributes of n matching the name test
+func (x *XPath) attributes(n *Node, name string) Nodes {
+	var nodes Nodes
+	for _, label := range n.Labels() {
+		if !x.isAttribute(label) || (name != "*" && x.attrPrefix+name != label) {
+			continue
+		}
+		nodes = append(nodes, n.Children[label]...)
+	}
+	return nodes
+}
+
+func (x *XPath) isAttribute(label string) bool {
+	return x.attrPrefix != "" && strings.HasPrefix(label, x.attrPrefix)
+}
+
+// descendantsOrSelf returns the nodes and all their element descendants
+func (x *XPath) descendantsOrSelf(nodes Nodes) Nodes {
+	var all Nodes
+	seen := map[*Node]bool{}
+
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		all = append(all, n)
+		for _, c := range x.elements(n, "*") {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return all
+}
+
+// nextStep splits the next step from the rest of the expression, which
+// starts with the "/" or "//" separator (if any)
+func (x *XPath) nextStep(s string) (string, string, error) {
+	depth := 0
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == '/' && depth == 0:
+			if i == 0 {
+				return "", "", x.errorf("empty step")
+			}
+			return s[:i], s[i:], nil
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return "", "", x.errorf("unbalanced quotes or brackets")
+	}
+	if s == "" {
+		return "", "", x.errorf("empty step")
+	}
+	return s, "", nil
+}
+
+func (x *XPath) parseStep(raw string) (xpathStep, error) {
+	test := raw
+	var predicates []string
+	if i := strings.Index(raw, "["); i >= 0 {
+		test = raw[:i]
+		rest := raw[i:]
+		for rest != "" {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 0 {
+				return xpathStep{}, x.errorf("invalid predicate in %q", raw)
+			}
+			// Skip the brackets enclosed in quotes
+			for strings.Count(rest[:end], "'")%2 == 1 || strings.Count(rest[:end], `"`)%2 == 1 {
+				next := strings.Index(rest[end+1:], "]")
+				if next < 0 {
+					return xpathStep{}, x.errorf("invalid predicate in %q", raw)
+				}
+				end += next + 1
+			}
+			predicates = append(predicates, strings.TrimSpace(rest[1:end]))
+			rest = rest[end+1:]
+		}
+	}
+
+	step := xpathStep{kind: "element", name: test}
+	switch {
+	case test == ".":
+		step.kind = "self"
+	case test == "text()":
+		step.kind = "text"
+	case strings.HasPrefix(test, "@"):
+		step.kind = "attribute"
+		step.name = test[1:]
+	}
+	if step.name == "" && step.kind != "self" && step.kind != "text" {
+		return xpathStep{}, x.errorf("missing name test in %q", raw)
+	}
+
+	for _, raw := range predicates {
+		p, err := x.parsePredicate(raw)
+		if err != nil {
+			return xpathStep{}, err
+		}
+		step.predicates = append(step.predicates, p)
+	}
+	return step, nil
+}
+
+func (x *XPath) parsePredicate(raw string) (xpathPredicate, error) {
+	if raw == "last()" {
+		return xpathPredicate{last: true}, nil
+	}
+	if n, err := strconv.Atoi(raw); err == nil {
+		if n < 1 {
+			return xpathPredicate{}, x.errorf("invalid position %d", n)
+		}
+		return xpathPredicate{position: n}, nil
+	}
+
+	p := xpathPredicate{exists: true}
+	operand := raw
+	if i := strings.Index(raw, "="); i > 0 {
+		p.exists = false
+		p.op = "="
+		operand = raw[:i]
+		if strings.HasSuffix(operand, "!") {
+			p.op = "!="
+			operand = operand[:len(operand)-1]
+		}
+		operand = strings.TrimSpace(operand)
+
+		value := strings.TrimSpace(raw[i+1:])
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] ||
+			strings.IndexByte(value[1:len(value)-1], value[0]) >= 0 {
+			return xpathPredicate{}, x.errorf("invalid literal %s", value)
+		}
+		p.value = value[1 : len(value)-1]
+	}
+
+	switch {
+	case operand == ".":
+		p.kind = "self"
+	case operand == "text()":
+		p.kind = "text"
+	case strings.HasPrefix(operand, "@") && isXPathName(operand[1:]):
+		p.kind = "attribute"
+		p.name = operand[1:]
+	case isXPathName(operand):
+		p.kind = "element"
+		p.name = operand
+	default:
+		return xpathPredicate{}, x.errorf("unsupported predicate [%s]", raw)
+	}
+	return p, nil
+}
+
+// isXPathName reports whether s is a name test without operators, e.g. not
+// "a<" in "a<'1'"
+func isXPathName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "/[]()'\"=!<>|,+@$ \t\r\n")
+}
+
+// errorf reports an invalid or unsupported expression as an
+// *UnsupportedError, at the path of the expression
+func (x *XPath) errorf(format string, args ...interface{}) error {
+	return &UnsupportedError{Position: Position{Path: x.expr}, Construct: "XPath " + fmt.Sprintf(format, args...)}
+}
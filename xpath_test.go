@@ -0,0 +1,139 @@
+package xml2json
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeOSM(t *testing.T) *Node {
+	root := &Node{}
+	err := NewDecoder(strings.NewReader(s)).Decode(root)
+	assert.NoError(t, err)
+	return root
+}
+
+func TestSelect(t *testing.T) {
+	assert := assert.New(t)
+	root := decodeOSM(t)
+
+	testCases := []struct {
+		expr  string
+		count int
+		first string
+	}{
+		{"/osm/node", 3, ""},
+		{"osm/node/@id", 3, "298884269"},
+		{"//tag", 2, ""},
+		{"osm//@k", 2, "name"},
+		{"//node[2]/@user", 1, "PikoWinter"},
+		{"//node[last()]/@user", 1, "lafkor"},
+		{"//node[@user='lafkor']/tag/@v", 2, "Neu Broderstorf"},
+		{"//node[@user!='lafkor']", 2, ""},
+		{"//node[tag]/@id", 1, "1831881213"},
+		{"//tag[@k=\"traffic_sign\"]/@v", 1, "city_limit"},
+		{"osm[foo='bar']/foo/text()", 1, "bar"},
+		{"osm/foo[text()='bar']", 1, "bar"},
+		{"osm/foo[.='baz']", 0, ""},
+		{"osm/*", 5, ""},
+		{"osm/bounds/@*", 4, ""},
+		{"osm/node/.", 3, ""},
+		{"//unknown", 0, ""},
+	}
+
+	for _, tc := range testCases {
+		nodes, err := root.Select(tc.expr)
+		if assert.NoError(err, tc.expr) && assert.Len(nodes, tc.count, tc.expr) && tc.first != "" {
+			assert.Equal(tc.first, nodes[0].Data, tc.expr)
+		}
+	}
+}
+
+func TestSelectOne(t *testing.T) {
+	assert := assert.New(t)
+	root := decodeOSM(t)
+
+	n, err := root.SelectOne("//node[3]/tag[1]/@v")
+	assert.NoError(err)
+	if assert.NotNil(n) {
+		assert.Equal("Neu Broderstorf", n.Data)
+	}
+
+	n, err = root.SelectOne("//way")
+	assert.NoError(err)
+	assert.Nil(n)
+}
+
+func TestSelectAttributePrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	dec := NewDecoder(strings.NewReader(`<a id="1"><b>x</b></a>`), WithAttrPrefix("@"))
+	assert.NoError(dec.Decode(root))
+
+	x, err := CompileXPath("/a/@id")
+	assert.NoError(err)
+	assert.Len(x.Select(root), 0)
+
+	x.SetAttributePrefix("@")
+	if nodes := x.Select(root); assert.Len(nodes, 1) {
+		assert.Equal("1", nodes[0].Data)
+	}
+	assert.Equal("/a/@id", x.String())
+}
+
+func TestCompileXPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"a//",
+		"a/[1]",
+		"a[1",
+		"a[@b='c]",
+		"a[0]",
+		"a[@b=c]",
+		"a[count(b)]",
+		"a[@b='c' and @d='e']",
+		`a[@b="c" or @d="e"]`,
+		"a[@b<'c']",
+		"a[@b>='c']",
+		"a[b<'c']",
+		"a[@b and @c]",
+		"@",
+	} {
+		_, err := CompileXPath(expr)
+		assert.True(t, errors.Is(err, ErrUnsupported), "%s: expected an *UnsupportedError, got %v", expr, err)
+	}
+}
+
+// TestSelectDocumentOrder ensures that wildcards and descendants keep the
+// document order of elements with different names
+func TestSelectDocumentOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	root := &Node{}
+	assert.NoError(NewDecoder(strings.NewReader(`<r><a i="1"/><b i="2"/><a i="3"><c i="4"/></a></r>`)).Decode(root))
+
+	testCases := []struct {
+		expr     string
+		expected []string
+	}{
+		{"r/*[2]/@i", []string{"2"}},
+		{"r/*[last()]/@i", []string{"3"}},
+		{"r/*/@i", []string{"1", "2", "3"}},
+		{"//@i", []string{"1", "2", "3", "4"}},
+		{"r/a/@i", []string{"1", "3"}},
+	}
+
+	for _, tc := range testCases {
+		nodes, err := root.Select(tc.expr)
+		if assert.NoError(err, tc.expr) {
+			var values []string
+			for _, n := range nodes {
+				values = append(values, n.Data)
+			}
+			assert.Equal(tc.expected, values, tc.expr)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package xml2json
+
+import (
+	"encoding/xml"
+)
+
+const (
+	xsiPrefix = "xsi"
+	xsiURL    = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// SetSchemaInstance controls whether the xsi:nil and xsi:type attributes
+// type the elements they are set on, instead of being decoded as
+// attributes. An element with xsi:nil="true" is encoded as null, and one
+// with a built-in xsi:type such as xs:int, xs:double or xs:boolean is
+// encoded as a JSON number or boolean. Both attributes are dropped.
+//
+// Types given by the document take precedence over type rules and schemas.
+func (dec *Decoder) SetSchemaInstance(enabled bool) {
+	dec.schemaInstance = enabled
+}
+
+// schemaInstanceAttr types the element from an xsi:nil or xsi:type
+// attribute, and reports whether the attribute has been consumed
+func (dec *Decoder) schemaInstanceAttr(e *element, a xml.Attr) bool {
+	// Undeclared prefixes are left as is by the tokenizer
+	if !dec.schemaInstance || (a.Name.Space != xsiURL && a.Name.Space != xsiPrefix) {
+		return false
+	}
+
+	switch a.Name.Local {
+	case "nil":
+		if a.Value == "true" || a.Value == "1" {
+			e.n.setInstanceType(Null)
+		}
+	case "type":
+		prefix, local := splitQName(a.Value)
+		if uri, ok := e.lookupURI(prefix); ok && uri == xsdURL {
+			// Nil elements stay null whatever their type
+			if t, typed := e.n.Type(); !typed || t != Null {
+				e.n.setInstanceType(builtinType(local))
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// lookupURI returns the namespace URI bound to the given prefix in scope of
+// the element
+func (e *element) lookupURI(prefix string) (string, bool) {
+	for ; e != nil; e = e.parent {
+		for i := len(e.bindings) - 1; i >= 0; i-- {
+			if b := e.bindings[i]; b.prefix == prefix {
+				return b.uri, true
+			}
+		}
+	}
+	return "", false
+}
+
+// setInstanceType sets the type of the node from the document itself
+func (n *Node) setInstanceType(t JSType) {
+	n.SetType(t)
+	n.instanceTyped = true
+}
@@ -0,0 +1,50 @@
+package xml2json
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const instanceDoc = `<order xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <price xsi:nil="true"/>
+  <discount xsi:nil="false">0.5</discount>
+  <quantity xsi:type="xs:int">3</quantity>
+  <total xsi:type="xs:double">12.50</total>
+  <gift xsi:type="xs:boolean">1</gift>
+  <zip xsi:type="xs:string">01234</zip>
+  <note xsi:type="xs:int" xsi:nil="true"/>
+  <custom xsi:type="my:type">x</custom>
+</order>`
+
+func TestSchemaInstance(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(instanceDoc), WithSchemaInstance(), WithTypeConverter(Float), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"order":{"-xsi":"http://www.w3.org/2001/XMLSchema-instance","-xs":"http://www.w3.org/2001/XMLSchema","price":null,"discount":0.5,"quantity":3,"total":12.5,"gift":true,"zip":"01234","note":null,"custom":"x"}}`+"\n", res.String())
+
+	// Types given by the document take precedence over type rules
+	res, err = Convert(strings.NewReader(instanceDoc),
+		WithSchemaInstance(),
+		WithTypeRules(map[string]JSType{"**.quantity": String, "**.price": Int, "**.custom": Int}),
+		WithCompact(),
+	)
+	assert.NoError(err)
+	assert.Contains(res.String(), `"price":null,"discount":"0.5","quantity":3`)
+	assert.Contains(res.String(), `"custom":"x"`)
+
+	// Without the option, xsi attributes are decoded as any other
+	res, err = Convert(strings.NewReader(instanceDoc), WithCompact())
+	assert.NoError(err)
+	assert.Contains(res.String(), `"price":{"-nil":"true"}`)
+}
+
+func TestSchemaInstanceUndeclaredPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := Convert(strings.NewReader(`<a><b xsi:nil="true"/></a>`), WithSchemaInstance(), WithCompact())
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":null}}`+"\n", res.String())
+}